@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Run dispatches arcane subcommands. It reports false when args do not name a
+// subcommand, in which case the caller should start the TUI.
+func Run(args []string, stdout, stderr io.Writer) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "export":
+		return true, runExport(args[1:], stdout, stderr)
+	case "history":
+		return true, runHistory(args[1:], stdout, stderr)
+	case "import":
+		return true, runImport(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		printUsage(stdout)
+		return true, nil
+	}
+	return false, nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
+  arcane                                   Start the TUI
+  arcane --resume <chat-id>                Start the TUI in a stored chat
+  arcane --continue [--here]               Start the TUI in the most recent chat
+                                           (--here: started in the current directory)
+  arcane export <chat-id> [--format md|json|html] [-o file]
+                                           Write a chat transcript (stdout by default)
+  arcane import [--format json|jsonl] <file|->...
+                                           Import chats from Arcane JSON exports or
+                                           OpenAI-style chat JSONL; duplicates are skipped
+  arcane history list [--limit n] [--here] [--json]
+                                           List recent chats (--here: current project)
+  arcane history show <chat-id> [--json]   Print a chat's active branch
+  arcane history rm <chat-id>...           Delete chats
+  arcane history search <query> [--limit n] [--json]
+                                           Find chats by message content
+`)
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments, which the standard flag package does not allow.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func parseChatID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid chat id %q", s)
+	}
+	return id, nil
+}
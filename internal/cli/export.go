@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"arcane/internal/config"
+	"arcane/internal/db"
+	"arcane/internal/export"
+	"arcane/internal/styles"
+)
+
+func runExport(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "md", "output format: md, json or html")
+	out := fs.String("o", "", "write to file instead of stdout")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: arcane export <chat-id> [--format md|json|html] [-o file]")
+	}
+	chatID, err := parseChatID(positional[0])
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.OpenArcaneDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	t, err := export.Load(conn, chatID)
+	if err != nil {
+		return err
+	}
+	if *format == "html" {
+		useConfiguredTheme()
+	}
+	data, err := export.Render(t, *format)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "Exported chat %d to %s\n", chatID, *out)
+	return nil
+}
+
+// useConfiguredTheme styles HTML exports with the theme named in the config,
+// as /export in the TUI does. With "auto" the dark theme is kept, since the
+// output need not be a terminal. Config and theme problems are left for the
+// TUI to report.
+func useConfiguredTheme() {
+	cfg, _ := config.Load()
+	if cfg.Theme == "" || cfg.Theme == "auto" {
+		return
+	}
+	themes, _ := styles.LoadThemes()
+	if t, ok := styles.FindTheme(themes, cfg.Theme); ok {
+		styles.Apply(t)
+	}
+}
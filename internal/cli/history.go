@@ -0,0 +1,315 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"arcane/internal/db"
+	"arcane/internal/export"
+	"arcane/internal/models"
+	"arcane/internal/project"
+)
+
+const historyUsage = `usage:
+  arcane history list [--limit n] [--here] [--json]
+  arcane history show <chat-id> [--json]
+  arcane history rm <chat-id>...
+  arcane history search <query> [--limit n] [--json]`
+
+func runHistory(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(historyUsage)
+	}
+	switch args[0] {
+	case "list", "ls":
+		return runHistoryList(args[1:], stdout, stderr)
+	case "show":
+		return runHistoryShow(args[1:], stdout, stderr)
+	case "rm", "delete":
+		return runHistoryRm(args[1:], stdout, stderr)
+	case "search":
+		return runHistorySearch(args[1:], stdout, stderr)
+	default:
+		return fmt.Errorf("unknown history command %q\n%s", args[0], historyUsage)
+	}
+}
+
+// chatJSON is the --json form of a chat list entry
+type chatJSON struct {
+	ID         int64     `json:"id"`
+	Title      string    `json:"title"`
+	ModelID    string    `json:"model_id"`
+	Project    string    `json:"project,omitempty"`
+	WorkingDir string    `json:"working_dir,omitempty"`
+	GitRoot    string    `json:"git_root,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func newChatJSON(c models.ChatListItem) chatJSON {
+	return chatJSON{
+		ID:         c.ID,
+		Title:      export.Title(c.LastUserPrompt, nil),
+		ModelID:    c.ModelID,
+		Project:    project.Name(c.WorkingDir, c.GitRoot),
+		WorkingDir: c.WorkingDir,
+		GitRoot:    c.GitRoot,
+		CreatedAt:  time.Unix(c.CreatedAtUnix, 0).UTC(),
+		UpdatedAt:  time.Unix(c.UpdatedAtUnix, 0).UTC(),
+	}
+}
+
+type searchHitJSON struct {
+	chatJSON
+	MessageID int64  `json:"message_id"`
+	Role      string `json:"role"`
+	Snippet   string `json:"snippet"`
+	Matches   int    `json:"matches"`
+}
+
+func runHistoryList(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("history list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	limit := fs.Int("limit", 20, "maximum number of chats")
+	here := fs.Bool("here", false, "only chats from the current project")
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 || *limit < 1 {
+		return fmt.Errorf("usage: arcane history list [--limit n] [--here] [--json]")
+	}
+	scope := ""
+	if *here {
+		if scope, err = currentProject(); err != nil {
+			return err
+		}
+	}
+
+	conn, err := db.OpenArcaneDBReadOnly()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	total, chats, err := db.GetRecentChats(conn, scope, *limit, 0)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		out := make([]chatJSON, 0, len(chats))
+		for _, c := range chats {
+			out = append(out, newChatJSON(c))
+		}
+		return writeJSON(stdout, out)
+	}
+
+	if len(chats) == 0 {
+		fmt.Fprintln(stderr, "No chats yet")
+		return nil
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tPROJECT\tMODEL\tTITLE")
+	for _, c := range chats {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", c.ID, formatUnix(c.UpdatedAtUnix), project.Name(c.WorkingDir, c.GitRoot), c.ModelID, clip(export.Title(c.LastUserPrompt, nil), 60))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if total > len(chats) {
+		fmt.Fprintf(stderr, "Showing %d of %d chats (use --limit for more)\n", len(chats), total)
+	}
+	return nil
+}
+
+func runHistoryShow(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("history show", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: arcane history show <chat-id> [--json]")
+	}
+	chatID, err := parseChatID(positional[0])
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.OpenArcaneDBReadOnly()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	t, err := export.Load(conn, chatID)
+	if err != nil {
+		return err
+	}
+	format := "md"
+	if *asJSON {
+		format = "json"
+	}
+	data, err := export.Render(t, format)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(data)
+	return err
+}
+
+func runHistoryRm(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("history rm", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: arcane history rm <chat-id>...")
+	}
+	ids := make([]int64, 0, len(positional))
+	for _, arg := range positional {
+		id, err := parseChatID(arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	conn, err := db.OpenArcaneDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var missing []string
+	for _, id := range ids {
+		deleted, err := db.DeleteChat(conn, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			missing = append(missing, fmt.Sprint(id))
+			continue
+		}
+		fmt.Fprintf(stdout, "Deleted chat %d\n", id)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("chat not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func runHistorySearch(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("history search", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	limit := fs.Int("limit", 20, "maximum number of chats")
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	query := strings.TrimSpace(strings.Join(positional, " "))
+	if query == "" || *limit < 1 {
+		return fmt.Errorf("usage: arcane history search <query> [--limit n] [--json]")
+	}
+
+	conn, err := db.OpenArcaneDBReadOnly()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hits, err := db.SearchChats(conn, query, *limit)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		out := make([]searchHitJSON, 0, len(hits))
+		for _, h := range hits {
+			out = append(out, searchHitJSON{
+				chatJSON:  newChatJSON(h.ChatListItem),
+				MessageID: h.MessageID,
+				Role:      h.Role,
+				Snippet:   snippet(h.Content, query, 120),
+				Matches:   h.Matches,
+			})
+		}
+		return writeJSON(stdout, out)
+	}
+
+	if len(hits) == 0 {
+		fmt.Fprintf(stderr, "No chats match %q\n", query)
+		return nil
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tHITS\tMATCH")
+	for _, h := range hits {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", h.ID, formatUnix(h.UpdatedAtUnix), h.Matches, snippet(h.Content, query, 70))
+	}
+	return tw.Flush()
+}
+
+// currentProject returns the project key for the current directory
+func currentProject() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return project.Key(cwd, project.GitRoot(cwd)), nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func formatUnix(ts int64) string {
+	return time.Unix(ts, 0).Format("2006-01-02 15:04")
+}
+
+// clip shortens s to at most n runes
+func clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// snippet returns up to n runes of single-line text around the first match of query
+func snippet(content, query string, n int) string {
+	text := strings.Join(strings.Fields(content), " ")
+	r := []rune(text)
+	if len(r) <= n {
+		return text
+	}
+	start := 0
+	if i := strings.Index(strings.ToLower(text), strings.ToLower(query)); i >= 0 && i <= len(text) {
+		start = len([]rune(text[:i])) - n/3
+	}
+	start = max(0, min(start, len(r)-n))
+	out := string(r[start : start+n])
+	if start > 0 {
+		out = "…" + string(r[start+1:start+n])
+	}
+	if start+n < len(r) {
+		out = string([]rune(out)[:n-1]) + "…"
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"arcane/internal/db"
+	"arcane/internal/export"
+	"arcane/internal/importer"
+)
+
+func runImport(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "input format: json or jsonl (detected by default)")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("usage: arcane import [--format json|jsonl] <file|->...")
+	}
+
+	// Parse everything first so a bad file does not leave a partial import
+	now := time.Now()
+	var transcripts []export.Transcript
+	for _, name := range files {
+		var data []byte
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return err
+		}
+		ts, err := importer.Parse(data, *format, now)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		transcripts = append(transcripts, ts...)
+	}
+
+	conn, err := db.OpenArcaneDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := importer.Save(conn, transcripts, now)
+	if err != nil {
+		return err
+	}
+	for _, id := range res.Imported {
+		fmt.Fprintf(stdout, "Imported chat %d\n", id)
+	}
+	for _, id := range res.Skipped {
+		fmt.Fprintf(stdout, "Skipped duplicate of chat %d\n", id)
+	}
+	fmt.Fprintf(stderr, "%d imported, %d skipped\n", len(res.Imported), len(res.Skipped))
+	return nil
+}
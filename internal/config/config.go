@@ -0,0 +1,257 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config holds user settings loaded from config.json in the arcane config dir.
+// Every field is optional; missing values fall back to the defaults below.
+type Config struct {
+	// Retry controls how transient API failures are retried
+	Retry RetryConfig `json:"retry"`
+
+	// FallbackModels are tried in order once retries on the current model are exhausted
+	FallbackModels []string `json:"fallback_models"`
+
+	// Personas are named presets selected with /persona
+	Personas []Persona `json:"personas"`
+
+	// InputHistoryScope is "project" (default) to recall prompts typed in the
+	// current project, or "global" to recall prompts from everywhere
+	InputHistoryScope string `json:"input_history_scope"`
+
+	// Keys overrides keybindings by action name, e.g. {"history": "ctrl+o"}.
+	// An empty list unbinds the action.
+	Keys map[string]KeyList `json:"keys"`
+
+	// Theme names the color theme: a built-in one or a file in the themes
+	// dir. Empty or "auto" picks dark or light from the terminal background.
+	Theme string `json:"theme"`
+
+	// Notify announces answers that took a while, for when you have switched
+	// to another window
+	Notify NotifyConfig `json:"notify"`
+
+	// ReasoningEffort asks models that think before answering to think
+	// more or less, by model ID, e.g. {"openai/gpt-oss-120b:free": "high"}.
+	// Models not listed use their provider's default.
+	ReasoningEffort map[string]string `json:"reasoning_effort"`
+}
+
+// ReasoningEfforts are the accepted reasoning_effort values, least first
+var ReasoningEfforts = []string{"minimal", "low", "medium", "high"}
+
+// NotifyConfig picks how a finished request is announced. An empty Methods
+// list with no Command turns notifications off.
+type NotifyConfig struct {
+	AfterSeconds int      `json:"after_seconds"` // Only for requests that ran at least this long
+	Methods      []string `json:"methods"`       // "bell", "osc9" and/or "osc777"
+	Command      string   `json:"command"`       // Run with sh -c; see the README for its environment
+}
+
+// NotifyMethods are the known terminal notification methods. They are sent
+// in the order Notify.Methods lists them.
+var NotifyMethods = []string{"bell", "osc9", "osc777"}
+
+// KeyList is one key or a list of keys in bubbletea notation ("ctrl+b", "alt+enter")
+type KeyList []string
+
+// UnmarshalJSON accepts a single string as well as an array
+func (k *KeyList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*k = KeyList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("keys: want a key or a list of keys, got %s", data)
+	}
+	*k = many
+	return nil
+}
+
+// Persona replaces the built-in system prompt and optionally picks a model,
+// mode and tool set. Empty fields keep the current behaviour.
+type Persona struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	SystemPrompt string   `json:"system_prompt"` // {{cwd}} is replaced with the working directory
+	Model        string   `json:"model"`         // Model ID selected with the persona
+	Mode         string   `json:"mode"`          // "chat" or "agent"
+	Tools        []string `json:"tools"`         // Tools allowed in agent mode; empty allows all
+}
+
+type RetryConfig struct {
+	MaxAttempts int `json:"max_attempts"`  // Attempts per model, including the first
+	BaseDelayMs int `json:"base_delay_ms"` // Delay before the first retry
+	MaxDelayMs  int `json:"max_delay_ms"`  // Upper bound for a single delay, Retry-After included
+}
+
+// Default returns the configuration used when no config file exists
+func Default() Config {
+	return Config{
+		Retry: RetryConfig{
+			MaxAttempts: 4,
+			BaseDelayMs: 1000,
+			MaxDelayMs:  30000,
+		},
+		InputHistoryScope: "project",
+		Notify: NotifyConfig{
+			AfterSeconds: 30,
+			Methods:      []string{"bell"},
+		},
+	}
+}
+
+// Dir returns the arcane config directory, creating it if needed
+func Dir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		homeDir, herr := os.UserHomeDir()
+		if herr != nil {
+			return "", err
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+
+	dir := filepath.Join(configDir, "arcane")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Path returns the location of config.json
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Load reads config.json on top of the defaults. A missing file is not an error.
+func Load() (Config, error) {
+	cfg := Default()
+
+	path, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Default(), err
+	}
+	cfg.normalize()
+	return cfg, cfg.validate()
+}
+
+// Persona looks up a persona by case-insensitive name
+func (c Config) Persona(name string) (Persona, bool) {
+	for _, p := range c.Personas {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+func (c *Config) normalize() {
+	def := Default()
+	if c.Retry.MaxAttempts < 1 {
+		c.Retry.MaxAttempts = def.Retry.MaxAttempts
+	}
+	if c.Retry.BaseDelayMs <= 0 {
+		c.Retry.BaseDelayMs = def.Retry.BaseDelayMs
+	}
+	if c.Retry.MaxDelayMs < c.Retry.BaseDelayMs {
+		c.Retry.MaxDelayMs = max(def.Retry.MaxDelayMs, c.Retry.BaseDelayMs)
+	}
+	c.InputHistoryScope = strings.ToLower(strings.TrimSpace(c.InputHistoryScope))
+	if c.InputHistoryScope == "" {
+		c.InputHistoryScope = "project"
+	}
+	for action, keys := range c.Keys {
+		for i, k := range keys {
+			keys[i] = strings.ToLower(strings.TrimSpace(k))
+		}
+		c.Keys[action] = slices.DeleteFunc(keys, func(k string) bool { return k == "" })
+	}
+	if c.Notify.AfterSeconds < 0 {
+		c.Notify.AfterSeconds = 0
+	}
+	for i, method := range c.Notify.Methods {
+		c.Notify.Methods[i] = strings.ToLower(strings.TrimSpace(method))
+	}
+	c.Notify.Command = strings.TrimSpace(c.Notify.Command)
+	c.Theme = strings.ToLower(strings.TrimSpace(c.Theme))
+	for id, effort := range c.ReasoningEffort {
+		c.ReasoningEffort[id] = strings.ToLower(strings.TrimSpace(effort))
+	}
+	for i := range c.Personas {
+		c.Personas[i].Name = strings.TrimSpace(c.Personas[i].Name)
+		c.Personas[i].Mode = strings.ToLower(strings.TrimSpace(c.Personas[i].Mode))
+	}
+}
+
+// validate reports settings that cannot be used. Invalid entries are dropped
+// so the rest of the config still applies.
+func (c *Config) validate() error {
+	var errs []error
+	seen := map[string]bool{}
+	personas := c.Personas[:0]
+	for i, p := range c.Personas {
+		key := strings.ToLower(p.Name)
+		switch {
+		case p.Name == "":
+			errs = append(errs, fmt.Errorf("personas[%d]: missing name", i))
+		case seen[key]:
+			errs = append(errs, fmt.Errorf("persona %q: defined more than once", p.Name))
+		case p.Mode != "" && p.Mode != "chat" && p.Mode != "agent":
+			errs = append(errs, fmt.Errorf("persona %q: mode must be \"chat\" or \"agent\"", p.Name))
+		default:
+			seen[key] = true
+			personas = append(personas, p)
+		}
+	}
+	c.Personas = personas
+
+	if c.InputHistoryScope != "project" && c.InputHistoryScope != "global" {
+		errs = append(errs, fmt.Errorf("input_history_scope must be \"project\" or \"global\""))
+		c.InputHistoryScope = "project"
+	}
+
+	methods := c.Notify.Methods[:0]
+	for _, method := range c.Notify.Methods {
+		if slices.Contains(NotifyMethods, method) {
+			methods = append(methods, method)
+		} else {
+			errs = append(errs, fmt.Errorf("notify: unknown method %q (want %s)", method, strings.Join(NotifyMethods, ", ")))
+		}
+	}
+	c.Notify.Methods = methods
+
+	for _, id := range slices.Sorted(maps.Keys(c.ReasoningEffort)) {
+		if effort := c.ReasoningEffort[id]; !slices.Contains(ReasoningEfforts, effort) {
+			errs = append(errs, fmt.Errorf("reasoning_effort %q: unknown effort %q (want %s)", id, effort, strings.Join(ReasoningEfforts, ", ")))
+			delete(c.ReasoningEffort, id)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -2,29 +2,65 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"arcane/internal/config"
 	"arcane/internal/models"
 	_ "modernc.org/sqlite"
 )
 
-func OpenArcaneDB() (*sql.DB, error) {
-	configDir, err := os.UserConfigDir()
+// schemaVersion is stored in PRAGMA user_version once migrate has run, so
+// read-only connections can tell whether the schema is current
+const schemaVersion = 7
+
+func dbPath() (string, error) {
+	dbDir, err := config.Dir()
 	if err != nil {
-		homeDir, herr := os.UserHomeDir()
-		if herr != nil {
-			return nil, err
-		}
-		configDir = filepath.Join(homeDir, ".config")
+		return "", err
+	}
+	return filepath.Join(dbDir, "arcane.db"), nil
+}
+
+// OpenArcaneDBReadOnly opens the history database without write access. It
+// falls back to OpenArcaneDB when the database does not exist yet or still
+// needs migrating.
+func OpenArcaneDBReadOnly() (*sql.DB, error) {
+	path, err := dbPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return OpenArcaneDB()
+	}
+
+	db, err := sql.Open("sqlite", "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if version < schemaVersion {
+		_ = db.Close()
+		return OpenArcaneDB()
 	}
+	return db, nil
+}
 
-	dbDir := filepath.Join(configDir, "arcane")
-	if err := os.MkdirAll(dbDir, 0o700); err != nil {
+func OpenArcaneDB() (*sql.DB, error) {
+	dbPath, err := dbPath()
+	if err != nil {
 		return nil, err
 	}
 
-	dbPath := filepath.Join(dbDir, "arcane.db")
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, err
@@ -66,15 +102,130 @@ func OpenArcaneDB() (*sql.DB, error) {
 		}
 	}
 
+	if err := migrate(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
 
-func CreateChat(db *sql.DB, nowUnix int64, modelID string) (int64, error) {
+// migrate adds columns introduced after the initial schema
+func migrate(db *sql.DB) error {
+	added, err := addColumnIfMissing(db, "messages", "parent_id", "INTEGER")
+	if err != nil {
+		return err
+	}
+	if added {
+		// Existing chats are linear: each message hangs off the one before it
+		if _, err := db.Exec(`UPDATE messages SET parent_id = (
+			SELECT MAX(p.id) FROM messages p WHERE p.chat_id = messages.chat_id AND p.id < messages.id
+		)`); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(chat_id, parent_id);`); err != nil {
+		return err
+	}
+
+	if _, err := addColumnIfMissing(db, "chats", "leaf_message_id", "INTEGER"); err != nil {
+		return err
+	}
+
+	if _, err := addColumnIfMissing(db, "chats", "import_hash", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_chats_import_hash ON chats(import_hash);`); err != nil {
+		return err
+	}
+
+	if _, err := addColumnIfMissing(db, "chats", "mode", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "chats", "working_dir", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "chats", "git_root", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "chats", "system_prompt", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "chats", "persona", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS input_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		text TEXT NOT NULL,
+		project TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);`); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_input_history_project ON input_history(project, id);`); err != nil {
+		return err
+	}
+
+	for _, col := range []struct{ name, decl string }{
+		{"model_id", "TEXT NOT NULL DEFAULT ''"},
+		{"prompt_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"completion_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"tool_calls", "TEXT NOT NULL DEFAULT ''"},
+		{"reasoning", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		if _, err := addColumnIfMissing(db, "messages", col.name, col.decl); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion))
+	return err
+}
+
+func addColumnIfMissing(db *sql.DB, table, column, decl string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	rows.Close()
+
+	if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + decl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// CreateChat inserts an empty chat created at chat.CreatedAtUnix
+func CreateChat(db execer, chat models.ChatListItem) (int64, error) {
 	res, err := db.Exec(
-		"INSERT INTO chats(created_at, updated_at, model_id, last_user_prompt) VALUES(?, ?, ?, '')",
-		nowUnix,
-		nowUnix,
-		modelID,
+		"INSERT INTO chats(created_at, updated_at, model_id, last_user_prompt, mode, working_dir, git_root, system_prompt, persona) VALUES(?, ?, ?, '', ?, ?, ?, ?, ?)",
+		chat.CreatedAtUnix,
+		chat.CreatedAtUnix,
+		chat.ModelID,
+		chat.Mode,
+		chat.WorkingDir,
+		chat.GitRoot,
+		chat.SystemPrompt,
+		chat.Persona,
 	)
 	if err != nil {
 		return 0, err
@@ -82,28 +233,95 @@ func CreateChat(db *sql.DB, nowUnix int64, modelID string) (int64, error) {
 	return res.LastInsertId()
 }
 
-func InsertDBMessage(db *sql.DB, chatID int64, role, content string, nowUnix int64) error {
+// InsertDBMessage stores msg as a child of msg.ParentID (0 for the first
+// message of a branch) and makes it the chat's active leaf.
+func InsertDBMessage(db execer, chatID int64, msg models.DBMessage) (int64, error) {
+	toolCalls := ""
+	if len(msg.ToolCalls) > 0 {
+		data, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			return 0, err
+		}
+		toolCalls = string(data)
+	}
+
+	res, err := db.Exec(
+		`INSERT INTO messages(chat_id, parent_id, role, content, created_at, model_id, prompt_tokens, completion_tokens, tool_calls, reasoning)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		chatID,
+		nullableID(msg.ParentID),
+		msg.Role,
+		msg.Content,
+		msg.CreatedAtUnix,
+		msg.ModelID,
+		msg.PromptTokens,
+		msg.CompletionTokens,
+		toolCalls,
+		msg.Reasoning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, SetChatLeaf(db, chatID, id)
+}
+
+// SetChatLeaf selects which branch of a chat is active
+func SetChatLeaf(db execer, chatID, leafID int64) error {
 	_, err := db.Exec(
-		"INSERT INTO messages(chat_id, role, content, created_at) VALUES(?, ?, ?, ?)",
+		"UPDATE chats SET leaf_message_id = ? WHERE id = ?",
+		nullableID(leafID),
 		chatID,
-		role,
-		content,
-		nowUnix,
 	)
 	return err
 }
 
-func UpdateChatOnUser(db *sql.DB, chatID int64, nowUnix int64, modelID, lastUserPrompt string) error {
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func UpdateChatOnUser(db *sql.DB, chatID int64, nowUnix int64, modelID string, mode models.AppMode, systemPrompt, lastUserPrompt string) error {
 	_, err := db.Exec(
-		"UPDATE chats SET updated_at = ?, model_id = ?, last_user_prompt = ? WHERE id = ?",
+		"UPDATE chats SET updated_at = ?, model_id = ?, mode = ?, system_prompt = ?, last_user_prompt = ? WHERE id = ?",
 		nowUnix,
 		modelID,
+		mode,
+		systemPrompt,
 		lastUserPrompt,
 		chatID,
 	)
 	return err
 }
 
+// SetChatPersona records a persona switch with the model and system prompt it selected
+func SetChatPersona(db *sql.DB, chatID int64, persona, modelID, systemPrompt string) error {
+	_, err := db.Exec(
+		"UPDATE chats SET persona = ?, model_id = ?, system_prompt = ? WHERE id = ?",
+		persona,
+		modelID,
+		systemPrompt,
+		chatID,
+	)
+	return err
+}
+
+// SetChatMode records a mode switch and the system prompt that goes with it
+func SetChatMode(db *sql.DB, chatID int64, mode models.AppMode, systemPrompt string) error {
+	_, err := db.Exec(
+		"UPDATE chats SET mode = ?, system_prompt = ? WHERE id = ?",
+		mode,
+		systemPrompt,
+		chatID,
+	)
+	return err
+}
+
 func TouchChat(db *sql.DB, chatID int64, nowUnix int64) error {
 	_, err := db.Exec(
 		"UPDATE chats SET updated_at = ? WHERE id = ?",
@@ -113,16 +331,46 @@ func TouchChat(db *sql.DB, chatID int64, nowUnix int64) error {
 	return err
 }
 
-func GetRecentChats(db *sql.DB, limit, offset int) (int, []models.ChatListItem, error) {
+// chatColumns lists the columns read by scanChat, qualified by the alias c
+const chatColumns = "c.id, c.created_at, c.updated_at, c.last_user_prompt, c.model_id, c.mode, c.working_dir, c.git_root, c.system_prompt, c.persona"
+
+// projectKey is a chat's project key (see project.Key). Chats stored before
+// projects were recorded, and imported ones, have none.
+const projectKey = "(CASE WHEN c.git_root != '' THEN c.git_root ELSE c.working_dir END)"
+
+// projectWhere matches chats belonging to a project key
+const projectWhere = projectKey + " = ?"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanChat(row scanner, extra ...any) (models.ChatListItem, error) {
+	var it models.ChatListItem
+	dest := append([]any{&it.ID, &it.CreatedAtUnix, &it.UpdatedAtUnix, &it.LastUserPrompt, &it.ModelID, &it.Mode, &it.WorkingDir, &it.GitRoot, &it.SystemPrompt, &it.Persona}, extra...)
+	return it, row.Scan(dest...)
+}
+
+// GetRecentChats returns a page of chats, most recently updated first. A
+// non-empty project limits the list to that project's chats and the chats
+// without a project, so history from before projects were recorded is not
+// hidden.
+func GetRecentChats(db *sql.DB, project string, limit, offset int) (int, []models.ChatListItem, error) {
+	where := ""
+	var args []any
+	if project != "" {
+		where = " WHERE " + projectKey + " IN (?, '')"
+		args = append(args, project)
+	}
+
 	var count int
-	if err := db.QueryRow("SELECT COUNT(*) FROM chats").Scan(&count); err != nil {
+	if err := db.QueryRow("SELECT COUNT(*) FROM chats c"+where, args...).Scan(&count); err != nil {
 		return 0, nil, err
 	}
 
 	rows, err := db.Query(
-		"SELECT id, updated_at, last_user_prompt, model_id FROM chats ORDER BY updated_at DESC LIMIT ? OFFSET ?",
-		limit,
-		offset,
+		"SELECT "+chatColumns+" FROM chats c"+where+" ORDER BY c.updated_at DESC LIMIT ? OFFSET ?",
+		append(args, limit, offset)...,
 	)
 	if err != nil {
 		return 0, nil, err
@@ -131,8 +379,8 @@ func GetRecentChats(db *sql.DB, limit, offset int) (int, []models.ChatListItem,
 
 	items := make([]models.ChatListItem, 0, limit)
 	for rows.Next() {
-		var it models.ChatListItem
-		if err := rows.Scan(&it.ID, &it.UpdatedAtUnix, &it.LastUserPrompt, &it.ModelID); err != nil {
+		it, err := scanChat(rows)
+		if err != nil {
 			return 0, nil, err
 		}
 		items = append(items, it)
@@ -144,9 +392,332 @@ func GetRecentChats(db *sql.DB, limit, offset int) (int, []models.ChatListItem,
 	return count, items, nil
 }
 
+// ForkChat copies the active branch of a chat into a new chat, stopping after
+// uptoMessageID when it is non-zero. The source chat is left untouched.
+func ForkChat(db *sql.DB, chatID, uptoMessageID int64, nowUnix int64) (int64, error) {
+	path, err := GetChatMessages(db, chatID)
+	if err != nil {
+		return 0, err
+	}
+	if uptoMessageID != 0 {
+		cut := -1
+		for i, m := range path {
+			if m.ID == uptoMessageID {
+				cut = i
+				break
+			}
+		}
+		if cut < 0 {
+			return 0, fmt.Errorf("message %d is not on the active branch of chat %d", uptoMessageID, chatID)
+		}
+		path = path[:cut+1]
+	}
+
+	src, err := GetChat(db, chatID)
+	if err != nil {
+		return 0, err
+	}
+	chat := models.ChatListItem{
+		CreatedAtUnix: nowUnix,
+		UpdatedAtUnix: nowUnix,
+		ModelID:       src.ModelID,
+		Mode:          src.Mode,
+		WorkingDir:    src.WorkingDir,
+		GitRoot:       src.GitRoot,
+		SystemPrompt:  src.SystemPrompt,
+		Persona:       src.Persona,
+	}
+	return InsertChat(db, chat, path, "")
+}
+
+// PromptPreview is the form of a prompt stored as a chat's last_user_prompt:
+// on one line and cut to 500 runes
+func PromptPreview(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "\r", " ")
+	s = strings.ReplaceAll(s, "\n", " ")
+	s = strings.Join(strings.Fields(s), " ")
+	const maxRunes = 500
+	r := []rune(s)
+	if len(r) > maxRunes {
+		return string(r[:maxRunes])
+	}
+	return s
+}
+
+// InsertChat stores a chat and its messages as a single branch in one
+// transaction. Message ParentIDs are ignored and rebuilt in order.
+// importHash identifies imported transcripts so re-imports can be skipped.
+func InsertChat(db *sql.DB, chat models.ChatListItem, msgs []models.DBMessage, importHash string) (int64, error) {
+	if chat.LastUserPrompt == "" {
+		for _, m := range msgs {
+			if m.Role == models.RoleUser {
+				chat.LastUserPrompt = PromptPreview(m.Content)
+			}
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var hash any
+	if importHash != "" {
+		hash = importHash
+	}
+	res, err := tx.Exec(
+		"INSERT INTO chats(created_at, updated_at, model_id, last_user_prompt, mode, working_dir, git_root, system_prompt, persona, import_hash) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		chat.CreatedAtUnix,
+		chat.UpdatedAtUnix,
+		chat.ModelID,
+		chat.LastUserPrompt,
+		chat.Mode,
+		chat.WorkingDir,
+		chat.GitRoot,
+		chat.SystemPrompt,
+		chat.Persona,
+		hash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	var parentID int64
+	for _, m := range msgs {
+		m.ParentID = parentID
+		id, err := InsertDBMessage(tx, newID, m)
+		if err != nil {
+			return 0, err
+		}
+		parentID = id
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
+
+// FindChatByImportHash returns the chat previously imported with hash, if any
+func FindChatByImportHash(db *sql.DB, hash string) (int64, bool, error) {
+	var id int64
+	err := db.QueryRow("SELECT id FROM chats WHERE import_hash = ?", hash).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// ChatsWithUserMessage returns the chats with a prompt of exactly content on
+// any branch, oldest first
+func ChatsWithUserMessage(db *sql.DB, content string) ([]int64, error) {
+	rows, err := db.Query(
+		"SELECT DISTINCT chat_id FROM messages WHERE role = ? AND content = ? ORDER BY chat_id",
+		models.RoleUser, content,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteChat removes a chat and all its messages. It reports false when the
+// chat does not exist.
+func DeleteChat(db *sql.DB, chatID int64) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Delete messages explicitly: foreign_keys is only enabled on one pooled connection
+	if _, err := tx.Exec("DELETE FROM messages WHERE chat_id = ?", chatID); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec("DELETE FROM chats WHERE id = ?", chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// SearchChats finds chats with a message containing query (case-insensitive
+// for ASCII), most recently updated first. Each hit carries the newest
+// matching message.
+func SearchChats(db *sql.DB, query string, limit int) ([]models.ChatSearchHit, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := db.Query(
+		"SELECT "+chatColumns+`, m.id, m.role, m.content
+		FROM messages m JOIN chats c ON c.id = m.chat_id
+		WHERE m.content LIKE ? ESCAPE '\'
+		ORDER BY c.updated_at DESC, c.id DESC, m.id DESC`,
+		pattern,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []models.ChatSearchHit
+	for rows.Next() {
+		var h models.ChatSearchHit
+		chat, err := scanChat(rows, &h.MessageID, &h.Role, &h.Content)
+		if err != nil {
+			return nil, err
+		}
+		h.ChatListItem = chat
+		if n := len(hits); n > 0 && hits[n-1].ID == h.ID {
+			hits[n-1].Matches++
+			continue
+		}
+		if limit > 0 && len(hits) == limit {
+			break
+		}
+		h.Matches = 1
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// GetChat returns the list entry for a single chat
+func GetChat(db *sql.DB, chatID int64) (models.ChatListItem, error) {
+	return scanChat(db.QueryRow("SELECT "+chatColumns+" FROM chats c WHERE c.id = ?", chatID))
+}
+
+// GetLatestChat returns the most recently updated chat, limited to the
+// project's chats when project is non-empty
+func GetLatestChat(db *sql.DB, project string) (models.ChatListItem, bool, error) {
+	query := "SELECT " + chatColumns + " FROM chats c"
+	var args []any
+	if project != "" {
+		query += " WHERE " + projectWhere
+		args = append(args, project)
+	}
+	it, err := scanChat(db.QueryRow(query+" ORDER BY c.updated_at DESC, c.id DESC LIMIT 1", args...))
+	if err == sql.ErrNoRows {
+		return it, false, nil
+	}
+	if err != nil {
+		return it, false, err
+	}
+	return it, true, nil
+}
+
+// GetChatMessages returns the active branch of a chat, oldest first
 func GetChatMessages(db *sql.DB, chatID int64) ([]models.DBMessage, error) {
+	var leaf sql.NullInt64
+	if err := db.QueryRow("SELECT leaf_message_id FROM chats WHERE id = ?", chatID).Scan(&leaf); err != nil {
+		return nil, err
+	}
+	return GetBranchMessages(db, chatID, leaf.Int64)
+}
+
+// GetBranchMessages returns the path from the first message down to leafID,
+// with sibling positions filled in. A zero leafID means the newest message.
+func GetBranchMessages(db *sql.DB, chatID, leafID int64) ([]models.DBMessage, error) {
+	all, err := getAllChatMessages(db, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return []models.DBMessage{}, nil
+	}
+
+	byID := make(map[int64]int, len(all))
+	children := make(map[int64][]int64)
+	for i, m := range all {
+		byID[m.ID] = i
+		children[m.ParentID] = append(children[m.ParentID], m.ID)
+	}
+	if _, ok := byID[leafID]; !ok {
+		leafID = all[len(all)-1].ID
+	}
+
+	var path []models.DBMessage
+	for id := leafID; id != 0; {
+		i, ok := byID[id]
+		if !ok {
+			break
+		}
+		m := all[i]
+		siblings := children[m.ParentID]
+		m.SiblingCount = len(siblings)
+		for si, sid := range siblings {
+			if sid == m.ID {
+				m.SiblingIndex = si
+			}
+		}
+		path = append(path, m)
+		id = m.ParentID
+	}
+	slices.Reverse(path)
+	return path, nil
+}
+
+// GetSiblingIDs returns the ids of messages sharing parentID, oldest first
+func GetSiblingIDs(db *sql.DB, chatID, parentID int64) ([]int64, error) {
 	rows, err := db.Query(
-		"SELECT role, content FROM messages WHERE chat_id = ? ORDER BY id ASC",
+		"SELECT id FROM messages WHERE chat_id = ? AND COALESCE(parent_id, 0) = ? ORDER BY id ASC",
+		chatID,
+		parentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// LatestLeaf returns the newest message in the subtree rooted at messageID
+func LatestLeaf(db *sql.DB, chatID, messageID int64) (int64, error) {
+	var leaf int64
+	err := db.QueryRow(`WITH RECURSIVE sub(id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT m.id FROM messages m JOIN sub ON m.parent_id = sub.id WHERE m.chat_id = ?
+		)
+		SELECT MAX(id) FROM sub`,
+		messageID,
+		chatID,
+	).Scan(&leaf)
+	return leaf, err
+}
+
+func getAllChatMessages(db *sql.DB, chatID int64) ([]models.DBMessage, error) {
+	rows, err := db.Query(
+		`SELECT id, COALESCE(parent_id, 0), role, content, created_at, model_id, prompt_tokens, completion_tokens, tool_calls, reasoning
+		FROM messages WHERE chat_id = ? ORDER BY id ASC`,
 		chatID,
 	)
 	if err != nil {
@@ -157,9 +728,16 @@ func GetChatMessages(db *sql.DB, chatID int64) ([]models.DBMessage, error) {
 	msgs := []models.DBMessage{}
 	for rows.Next() {
 		var m models.DBMessage
-		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+		var toolCalls string
+		if err := rows.Scan(&m.ID, &m.ParentID, &m.Role, &m.Content, &m.CreatedAtUnix,
+			&m.ModelID, &m.PromptTokens, &m.CompletionTokens, &toolCalls, &m.Reasoning); err != nil {
 			return nil, err
 		}
+		if toolCalls != "" {
+			if err := json.Unmarshal([]byte(toolCalls), &m.ToolCalls); err != nil {
+				return nil, err
+			}
+		}
 		msgs = append(msgs, m)
 	}
 	if err := rows.Err(); err != nil {
@@ -167,3 +745,45 @@ func GetChatMessages(db *sql.DB, chatID int64) ([]models.DBMessage, error) {
 	}
 	return msgs, nil
 }
+
+// maxInputHistory is how many input history rows are kept
+const maxInputHistory = 10000
+
+// AddInputHistory records a line entered in the input box. Repeating the
+// previous line of the same project does not add a row.
+func AddInputHistory(db *sql.DB, text, project string, nowUnix int64) error {
+	var last string
+	err := db.QueryRow(`SELECT text FROM input_history WHERE project = ? ORDER BY id DESC LIMIT 1`, project).Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if last == text {
+		return nil
+	}
+	if _, err := db.Exec(`INSERT INTO input_history (text, project, created_at) VALUES (?, ?, ?)`, text, project, nowUnix); err != nil {
+		return err
+	}
+	_, err = db.Exec(`DELETE FROM input_history WHERE id <= (SELECT MAX(id) FROM input_history) - ?`, maxInputHistory)
+	return err
+}
+
+// GetInputHistory returns distinct past inputs, newest first. An empty
+// project returns the inputs of every project.
+func GetInputHistory(db *sql.DB, project string, limit int) ([]string, error) {
+	rows, err := db.Query(`SELECT text FROM input_history
+		WHERE ? = '' OR project = ?
+		GROUP BY text ORDER BY MAX(id) DESC LIMIT ?`, project, project, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, err
+		}
+		out = append(out, text)
+	}
+	return out, rows.Err()
+}
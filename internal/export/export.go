@@ -0,0 +1,175 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"arcane/internal/db"
+	"arcane/internal/models"
+)
+
+// FormatVersion is bumped whenever the JSON layout changes incompatibly
+const FormatVersion = 1
+
+// Transcript is the portable form of a chat's active branch. Its JSON
+// encoding is the format written by `arcane export --format json`.
+type Transcript struct {
+	Version  int       `json:"version"`
+	Chat     Chat      `json:"chat"`
+	Messages []Message `json:"messages"`
+}
+
+type Chat struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	ModelID   string    `json:"model_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Message struct {
+	Role      string            `json:"role"`
+	Content   string            `json:"content"`
+	CreatedAt time.Time         `json:"created_at"`
+	ModelID   string            `json:"model_id,omitempty"`
+	Usage     *Usage            `json:"usage,omitempty"`
+	ToolCalls []models.ToolCall `json:"tool_calls,omitempty"`
+	Reasoning string            `json:"reasoning,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// Formats lists the supported export formats
+var Formats = []string{"md", "json", "html"}
+
+// Load builds the transcript for the active branch of a chat
+func Load(conn *sql.DB, chatID int64) (Transcript, error) {
+	chat, err := db.GetChat(conn, chatID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Transcript{}, fmt.Errorf("chat %d not found", chatID)
+		}
+		return Transcript{}, err
+	}
+	msgs, err := db.GetChatMessages(conn, chatID)
+	if err != nil {
+		return Transcript{}, err
+	}
+
+	t := Transcript{
+		Version: FormatVersion,
+		Chat: Chat{
+			ID:        chat.ID,
+			Title:     Title(chat.LastUserPrompt, msgs),
+			ModelID:   chat.ModelID,
+			CreatedAt: time.Unix(chat.CreatedAtUnix, 0).UTC(),
+			UpdatedAt: time.Unix(chat.UpdatedAtUnix, 0).UTC(),
+		},
+		Messages: make([]Message, 0, len(msgs)),
+	}
+	for _, m := range msgs {
+		msg := Message{
+			Role:      m.Role,
+			Content:   m.Content,
+			CreatedAt: time.Unix(m.CreatedAtUnix, 0).UTC(),
+			ModelID:   m.ModelID,
+			ToolCalls: m.ToolCalls,
+			Reasoning: m.Reasoning,
+		}
+		if m.PromptTokens > 0 || m.CompletionTokens > 0 {
+			msg.Usage = &Usage{PromptTokens: m.PromptTokens, CompletionTokens: m.CompletionTokens}
+		}
+		t.Messages = append(t.Messages, msg)
+	}
+	return t, nil
+}
+
+// Title picks a short title for a chat from its first prompt
+func Title(lastUserPrompt string, msgs []models.DBMessage) string {
+	title := ""
+	for _, m := range msgs {
+		if m.Role == models.RoleUser {
+			title = m.Content
+			break
+		}
+	}
+	if title == "" {
+		title = lastUserPrompt
+	}
+	title = strings.Join(strings.Fields(title), " ")
+	if r := []rune(title); len(r) > 80 {
+		title = string(r[:79]) + "…"
+	}
+	if title == "" {
+		title = "Untitled chat"
+	}
+	return title
+}
+
+// Usage sums token usage over all messages
+func (t Transcript) Usage() Usage {
+	var u Usage
+	for _, m := range t.Messages {
+		if m.Usage != nil {
+			u.PromptTokens += m.Usage.PromptTokens
+			u.CompletionTokens += m.Usage.CompletionTokens
+		}
+	}
+	return u
+}
+
+// Render encodes the transcript in the given format
+func Render(t Transcript, format string) ([]byte, error) {
+	switch format {
+	case "md", "markdown":
+		return []byte(Markdown(t)), nil
+	case "json":
+		return JSON(t)
+	case "html":
+		return HTML(t)
+	default:
+		return nil, fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// JSON encodes the transcript in the round-trippable JSON format
+func JSON(t Transcript) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FileName suggests a file name for an exported chat
+func FileName(chatID int64, format string) string {
+	if format == "markdown" {
+		format = "md"
+	}
+	return fmt.Sprintf("arcane-chat-%d.%s", chatID, format)
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case models.RoleUser:
+		return "You"
+	case models.RoleAssistant:
+		return "Arcane"
+	case "":
+		return "Unknown"
+	default:
+		return strings.ToUpper(role[:1]) + role[1:]
+	}
+}
+
+const timeLayout = "2006-01-02 15:04 MST"
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"arcane/internal/models"
+	"arcane/internal/styles"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// HTML renders the transcript as a single self-contained page styled like the TUI
+func HTML(t Transcript) ([]byte, error) {
+	type htmlMessage struct {
+		Message
+		Label string
+		User  bool
+		Event bool
+		Body  template.HTML
+	}
+	data := struct {
+		Transcript
+		Total    Usage
+		Layout   string
+		Messages []htmlMessage
+		Colors   [][2]string
+	}{
+		Transcript: t,
+		Total:      t.Usage(),
+		Layout:     timeLayout,
+		Colors:     themeColors(styles.CurrentTheme),
+	}
+
+	for _, m := range t.Messages {
+		if m.Role == models.RoleEvent {
+			data.Messages = append(data.Messages, htmlMessage{Message: m, Event: true})
+			continue
+		}
+		var body bytes.Buffer
+		// goldmark escapes raw HTML by default, so model output cannot inject markup
+		if err := markdown.Convert([]byte(m.Content), &body); err != nil {
+			return nil, err
+		}
+		data.Messages = append(data.Messages, htmlMessage{
+			Message: m,
+			Label:   strings.ToUpper(roleLabel(m.Role)),
+			User:    m.Role == models.RoleUser,
+			Body:    template.HTML(body.String()),
+		})
+	}
+
+	var out bytes.Buffer
+	if err := htmlTemplate.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// themeColors are the CSS variables for the page, taken from the theme the
+// TUI is using
+func themeColors(t styles.Theme) [][2]string {
+	return [][2]string{
+		{"primary", styles.Hex(t.Primary)},
+		{"secondary", styles.Hex(t.Secondary)},
+		{"accent", styles.Hex(t.Accent)},
+		{"highlight", styles.Hex(t.Highlight)},
+		{"tool-name", styles.Hex(t.ToolName)},
+		{"bg", styles.Hex(t.BgBase)},
+		{"bg-surface", styles.Hex(t.BgSurface)},
+		{"text", styles.Hex(t.Text)},
+		{"text-secondary", styles.Hex(t.TextSecondary)},
+		{"text-muted", styles.Hex(t.TextMuted)},
+		{"text-dim", styles.Hex(t.TextDim)},
+		{"on-color", styles.Hex(t.OnColor)},
+		{"on-accent", styles.Hex(t.OnAccent)},
+		{"border", styles.Hex(t.Border)},
+	}
+}
+
+var htmlTemplate = template.Must(template.New("chat").Funcs(template.FuncMap{
+	"css": func(s string) template.CSS { return template.CSS(s) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Chat.Title}} · Arcane</title>
+<style>
+  :root {
+{{- range .Colors}}
+    --{{index . 0}}: {{css (index . 1)}};
+{{- end}}
+  }
+  body { background: var(--bg); color: var(--text); margin: 0;
+         font: 14px/1.6 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+  main { max-width: 100ch; margin: 0 auto; padding: 2rem 1rem; }
+  h1.app { color: var(--primary); font-style: italic; font-size: 1rem; text-align: center; }
+  header.meta { color: var(--text-muted); border-bottom: 1px solid var(--border);
+                padding-bottom: 1rem; margin-bottom: 1.5rem; }
+  header.meta h2 { color: var(--text); font-size: 1.1rem; margin: 0 0 .5rem; }
+  .msg { margin: 0 0 1.75rem; }
+  .label { display: inline-block; font-weight: bold; padding: 0 .6em; margin-right: .6em; }
+  .user .label { background: var(--secondary); color: var(--on-color); }
+  .assistant .label, .other .label { background: var(--accent); color: var(--on-accent); }
+  .event { color: var(--text-dim); font-style: italic; margin: 0 0 1.75rem; }
+  .info { color: var(--text-dim); font-size: .85em; }
+  .body { margin-top: .4rem; padding-left: 1rem; border-left: 4px solid var(--accent); }
+  .user .body { border-left-color: var(--secondary); }
+  .body > :first-child { margin-top: 0; }
+  .body > :last-child { margin-bottom: 0; }
+  a { color: var(--secondary); }
+  code { background: var(--border); padding: 0 .25em; }
+  pre { background: var(--bg-surface); border: 1px solid var(--border); padding: .75rem; overflow-x: auto; }
+  pre code { background: none; padding: 0; }
+  table { border-collapse: collapse; }
+  th, td { border: 1px solid var(--border); padding: .25em .6em; }
+  details.tools { color: var(--text-dim); margin: .4rem 0 0 1rem; }
+  details.tools summary { cursor: pointer; }
+  details.thinking { color: var(--text-dim); margin: .4rem 0 0 1rem; }
+  details.thinking summary { cursor: pointer; }
+  details.thinking pre { color: var(--text-muted); font-style: italic; white-space: pre-wrap; max-height: 20em; }
+  .tool { margin: .3rem 0; }
+  .tool .arrow { color: var(--highlight); font-weight: bold; }
+  .tool .name { color: var(--tool-name); font-weight: bold; }
+  .tool pre { color: var(--text-secondary); max-height: 20em; }
+</style>
+</head>
+<body>
+<main>
+<h1 class="app">ARCANE AI</h1>
+<header class="meta">
+  <h2>{{.Chat.Title}}</h2>
+  <div>Chat #{{.Chat.ID}} · <code>{{.Chat.ModelID}}</code></div>
+  <div>Created {{.Chat.CreatedAt.Local.Format .Layout}} · Updated {{.Chat.UpdatedAt.Local.Format .Layout}}</div>
+  {{- if or .Total.PromptTokens .Total.CompletionTokens}}
+  <div>Usage: {{.Total.PromptTokens}} in / {{.Total.CompletionTokens}} out tokens</div>
+  {{- end}}
+</header>
+{{range .Messages}}
+{{- if .Event}}
+<p class="event">· {{.Content}} · {{.CreatedAt.Local.Format $.Layout}}</p>
+{{- else}}
+<section class="msg {{if .User}}user{{else if eq .Role "assistant"}}assistant{{else}}other{{end}}">
+  <span class="label">{{.Label}}</span>
+  <span class="info">{{.CreatedAt.Local.Format $.Layout}}{{if .ModelID}} · {{.ModelID}}{{end}}{{with .Usage}} · {{.PromptTokens}} in / {{.CompletionTokens}} out{{end}}</span>
+  {{- if .Reasoning}}
+  <details class="thinking">
+    <summary>Thinking</summary>
+    <pre>{{.Reasoning}}</pre>
+  </details>
+  {{- end}}
+  {{- if .ToolCalls}}
+  <details class="tools">
+    <summary>Tool calls ({{len .ToolCalls}})</summary>
+    {{- range .ToolCalls}}
+    <div class="tool">
+      <span class="arrow">→</span> <span class="name">{{if .Summary}}{{.Summary}}{{else}}{{.Name}}{{end}}</span>
+      {{- if .Arguments}}<pre><code>{{.Arguments}}</code></pre>{{end}}
+      {{- if .Result}}<pre><code>{{.Result}}</code></pre>{{end}}
+    </div>
+    {{- end}}
+  </details>
+  {{- end}}
+  <div class="body">{{.Body}}</div>
+</section>
+{{- end}}
+{{end}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"arcane/internal/models"
+)
+
+// Markdown renders the transcript as GitHub-flavoured Markdown, suitable for
+// pasting into issues and pull requests
+func Markdown(t Transcript) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# %s\n\n", t.Chat.Title)
+	fmt.Fprintf(&sb, "- **Chat:** #%d\n", t.Chat.ID)
+	fmt.Fprintf(&sb, "- **Model:** `%s`\n", t.Chat.ModelID)
+	fmt.Fprintf(&sb, "- **Created:** %s\n", t.Chat.CreatedAt.Local().Format(timeLayout))
+	fmt.Fprintf(&sb, "- **Updated:** %s\n", t.Chat.UpdatedAt.Local().Format(timeLayout))
+	if u := t.Usage(); u.PromptTokens > 0 || u.CompletionTokens > 0 {
+		fmt.Fprintf(&sb, "- **Usage:** %d in / %d out tokens\n", u.PromptTokens, u.CompletionTokens)
+	}
+
+	for _, m := range t.Messages {
+		if m.Role == models.RoleEvent {
+			fmt.Fprintf(&sb, "\n> _%s · %s_\n", strings.TrimSpace(m.Content), m.CreatedAt.Local().Format(timeLayout))
+			continue
+		}
+		sb.WriteString("\n---\n\n")
+
+		header := []string{roleLabel(m.Role), m.CreatedAt.Local().Format(timeLayout)}
+		if m.ModelID != "" {
+			header = append(header, "`"+m.ModelID+"`")
+		}
+		if m.Usage != nil {
+			header = append(header, fmt.Sprintf("%d in / %d out tokens", m.Usage.PromptTokens, m.Usage.CompletionTokens))
+		}
+		fmt.Fprintf(&sb, "### %s\n\n", strings.Join(header, " · "))
+
+		if reasoning := strings.TrimSpace(m.Reasoning); reasoning != "" {
+			fmt.Fprintf(&sb, "<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n\n", fence("", reasoning))
+		}
+		if len(m.ToolCalls) > 0 {
+			fmt.Fprintf(&sb, "<details>\n<summary>Tool calls (%d)</summary>\n\n", len(m.ToolCalls))
+			for _, tc := range m.ToolCalls {
+				label := tc.Summary
+				if label == "" {
+					label = tc.Name
+				}
+				fmt.Fprintf(&sb, "- **%s** `%s`\n", label, tc.Name)
+				if tc.Arguments != "" {
+					fmt.Fprintf(&sb, "\n%s\n", fence("json", tc.Arguments))
+				}
+				if tc.Result != "" {
+					fmt.Fprintf(&sb, "\n%s\n", fence("", tc.Result))
+				}
+			}
+			sb.WriteString("\n</details>\n\n")
+		}
+
+		sb.WriteString(strings.TrimSpace(m.Content))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// fence wraps s in a code fence longer than any backtick run inside it
+func fence(lang, s string) string {
+	ticks := "```"
+	for strings.Contains(s, ticks) {
+		ticks += "`"
+	}
+	indent := "  "
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = indent + l
+	}
+	return indent + ticks + lang + "\n" + strings.Join(lines, "\n") + "\n" + indent + ticks
+}
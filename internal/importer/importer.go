@@ -0,0 +1,346 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"arcane/internal/db"
+	"arcane/internal/export"
+	"arcane/internal/models"
+)
+
+// Result summarises an import
+type Result struct {
+	Imported []int64 // New chat IDs
+	Skipped  []int64 // Existing chats that matched an imported transcript
+}
+
+// Parse reads transcripts in Arcane's JSON export format (a single transcript
+// or an array of them) or in OpenAI chat JSONL. format is "json", "jsonl" or
+// "" to detect it from the content.
+func Parse(data []byte, format string, now time.Time) ([]export.Transcript, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("empty input")
+	}
+	if format == "" {
+		format = detectFormat(trimmed)
+	}
+
+	switch format {
+	case "json":
+		return parseArcane(trimmed)
+	case "jsonl":
+		return parseJSONL(trimmed, now)
+	default:
+		return nil, fmt.Errorf("unknown import format %q (want json or jsonl)", format)
+	}
+}
+
+// detectFormat tells our export JSON apart from JSONL, which also starts with '{'
+func detectFormat(data []byte) string {
+	if data[0] == '[' {
+		return "json"
+	}
+	var probe struct {
+		Version int             `json:"version"`
+		Chat    json.RawMessage `json:"chat"`
+	}
+	if err := json.Unmarshal(data, &probe); err == nil && (probe.Version > 0 || probe.Chat != nil) {
+		return "json"
+	}
+	return "jsonl"
+}
+
+func parseArcane(data []byte) ([]export.Transcript, error) {
+	var ts []export.Transcript
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &ts); err != nil {
+			return nil, err
+		}
+	} else {
+		var t export.Transcript
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	for i, t := range ts {
+		if t.Version > export.FormatVersion {
+			return nil, fmt.Errorf("transcript %d: format version %d is newer than supported (%d)", i+1, t.Version, export.FormatVersion)
+		}
+	}
+	return ts, nil
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls"`
+	ToolCallID string           `json:"tool_call_id"`
+}
+
+type openAILine struct {
+	openAIMessage
+	Messages []openAIMessage `json:"messages"`
+	Model    string          `json:"model"`
+	Created  int64           `json:"created"`
+}
+
+// parseJSONL reads one conversation per {"messages": [...]} line. Lines that
+// hold a single message are collected into one conversation.
+func parseJSONL(data []byte, now time.Time) ([]export.Transcript, error) {
+	var ts []export.Transcript
+	var loose openAILine
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var l openAILine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		switch {
+		case len(l.Messages) > 0:
+			t, err := fromOpenAI(l, now)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			ts = append(ts, t)
+		case l.Role != "":
+			loose.Messages = append(loose.Messages, l.openAIMessage)
+			if loose.Model == "" {
+				loose.Model = l.Model
+			}
+			if loose.Created == 0 {
+				loose.Created = l.Created
+			}
+		default:
+			return nil, fmt.Errorf("line %d: expected \"messages\" or \"role\"", lineNo)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(loose.Messages) > 0 {
+		t, err := fromOpenAI(loose, now)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+func fromOpenAI(l openAILine, now time.Time) (export.Transcript, error) {
+	created := now.UTC()
+	if l.Created > 0 {
+		created = time.Unix(l.Created, 0).UTC()
+	}
+
+	t := export.Transcript{
+		Version: export.FormatVersion,
+		Chat: export.Chat{
+			ModelID:   l.Model,
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+	}
+
+	// Tool calls and their results arrive as separate messages; fold them
+	// into the assistant message that follows, as Arcane stores them
+	var pending []models.ToolCall
+	pendingIdx := map[string]int{}
+	for _, m := range l.Messages {
+		content, err := decodeContent(m.Content)
+		if err != nil {
+			return t, err
+		}
+		switch m.Role {
+		case "system", "developer":
+			// Arcane builds its own system prompt
+		case "tool", "function":
+			if i, ok := pendingIdx[m.ToolCallID]; ok {
+				pending[i].Result = content
+			}
+		case models.RoleUser:
+			t.Messages = append(t.Messages, export.Message{Role: models.RoleUser, Content: content, CreatedAt: created})
+		case models.RoleAssistant:
+			for _, tc := range m.ToolCalls {
+				pendingIdx[tc.ID] = len(pending)
+				pending = append(pending, models.ToolCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+			}
+			if content == "" && len(m.ToolCalls) > 0 {
+				continue
+			}
+			t.Messages = append(t.Messages, export.Message{
+				Role:      models.RoleAssistant,
+				Content:   content,
+				CreatedAt: created,
+				ModelID:   l.Model,
+				ToolCalls: pending,
+			})
+			pending, pendingIdx = nil, map[string]int{}
+		default:
+			return t, fmt.Errorf("unsupported role %q", m.Role)
+		}
+	}
+	if len(pending) > 0 {
+		t.Messages = append(t.Messages, export.Message{Role: models.RoleAssistant, CreatedAt: created, ModelID: l.Model, ToolCalls: pending})
+	}
+	if len(t.Messages) == 0 {
+		return t, fmt.Errorf("conversation has no user or assistant messages")
+	}
+	return t, nil
+}
+
+// decodeContent accepts both a plain string and an array of content parts
+func decodeContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("unsupported message content: %s", raw)
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// Fingerprint identifies a conversation by its roles and contents, so the same
+// transcript is recognised whichever format or timestamps it was imported with
+func Fingerprint(t export.Transcript) string {
+	h := sha256.New()
+	for _, m := range t.Messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FindExisting returns a stored chat whose active branch holds the same
+// conversation as t, whether it was imported or written in Arcane, so
+// history moved back and forth between machines is not duplicated
+func FindExisting(conn *sql.DB, t export.Transcript, hash string) (int64, bool, error) {
+	if id, found, err := db.FindChatByImportHash(conn, hash); err != nil || found {
+		return id, found, err
+	}
+
+	// Native chats have no stored hash, and theirs changes as they grow, so
+	// compare the chats that share the first prompt
+	first := ""
+	for _, m := range t.Messages {
+		if m.Role == models.RoleUser {
+			first = m.Content
+			break
+		}
+	}
+	if first == "" {
+		return 0, false, nil
+	}
+	ids, err := db.ChatsWithUserMessage(conn, first)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, id := range ids {
+		stored, err := export.Load(conn, id)
+		if err != nil {
+			return 0, false, err
+		}
+		if Fingerprint(stored) == hash {
+			return id, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// Save stores transcripts as new chats, skipping ones already stored
+func Save(conn *sql.DB, ts []export.Transcript, now time.Time) (Result, error) {
+	var res Result
+	for _, t := range ts {
+		hash := Fingerprint(t)
+		if id, found, err := FindExisting(conn, t, hash); err != nil {
+			return res, err
+		} else if found {
+			res.Skipped = append(res.Skipped, id)
+			continue
+		}
+
+		chat := models.ChatListItem{
+			CreatedAtUnix: t.Chat.CreatedAt.Unix(),
+			UpdatedAtUnix: t.Chat.UpdatedAt.Unix(),
+			ModelID:       t.Chat.ModelID,
+		}
+		if t.Chat.CreatedAt.IsZero() {
+			chat.CreatedAtUnix = now.Unix()
+		}
+		if t.Chat.UpdatedAt.IsZero() {
+			chat.UpdatedAtUnix = chat.CreatedAtUnix
+		}
+
+		msgs := make([]models.DBMessage, 0, len(t.Messages))
+		for _, m := range t.Messages {
+			msg := models.DBMessage{
+				Role:          m.Role,
+				Content:       m.Content,
+				CreatedAtUnix: m.CreatedAt.Unix(),
+				ModelID:       m.ModelID,
+				ToolCalls:     m.ToolCalls,
+				Reasoning:     m.Reasoning,
+			}
+			if m.CreatedAt.IsZero() {
+				msg.CreatedAtUnix = chat.CreatedAtUnix
+			}
+			if m.Usage != nil {
+				msg.PromptTokens = m.Usage.PromptTokens
+				msg.CompletionTokens = m.Usage.CompletionTokens
+			}
+			if chat.ModelID == "" && m.ModelID != "" {
+				chat.ModelID = m.ModelID
+			}
+			msgs = append(msgs, msg)
+		}
+
+		id, err := db.InsertChat(conn, chat, msgs, hash)
+		if err != nil {
+			return res, err
+		}
+		res.Imported = append(res.Imported, id)
+	}
+	return res, nil
+}
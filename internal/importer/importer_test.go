@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"arcane/internal/db"
+	"arcane/internal/export"
+	"arcane/internal/models"
+)
+
+// nativeChat stores a chat the way the TUI does, without an import hash
+func nativeChat(t *testing.T, contents ...string) int64 {
+	t.Helper()
+	conn, err := db.OpenArcaneDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	chatID, err := db.CreateChat(conn, models.ChatListItem{CreatedAtUnix: 1, ModelID: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parent int64
+	for i, c := range contents {
+		role := models.RoleUser
+		if i%2 == 1 {
+			role = models.RoleAssistant
+		}
+		parent, err = db.InsertDBMessage(conn, chatID, models.DBMessage{ParentID: parent, Role: role, Content: c, CreatedAtUnix: int64(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chatID
+}
+
+func TestSaveSkipsChatsAlreadyStored(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	native := nativeChat(t, "What is 6*7?", "42", "Thanks")
+
+	conn, err := db.OpenArcaneDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// An export of a chat written here is the chat itself
+	exported, err := export.Load(conn, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Save(conn, []export.Transcript{exported}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Imported) != 0 || len(res.Skipped) != 1 || res.Skipped[0] != native {
+		t.Fatalf("importing the export of chat %d gave %+v", native, res)
+	}
+
+	// The same conversation taken further elsewhere is new here, once
+	exported.Messages = append(exported.Messages, export.Message{Role: models.RoleAssistant, Content: "You're welcome"})
+	res, err = Save(conn, []export.Transcript{exported}, time.Now())
+	if err != nil || len(res.Imported) != 1 {
+		t.Fatalf("importing a longer conversation gave %+v, %v", res, err)
+	}
+	imported := res.Imported[0]
+	res, err = Save(conn, []export.Transcript{exported}, time.Now())
+	if err != nil || len(res.Skipped) != 1 || res.Skipped[0] != imported {
+		t.Fatalf("importing it again gave %+v, %v", res, err)
+	}
+
+	// A chat with the same first prompt but a different answer is not a duplicate
+	other := nativeChat(t, "What is 6*7?", "Forty-two")
+	otherExport, err := export.Load(conn, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherExport.Messages[1].Content = "Six times seven"
+	res, err = Save(conn, []export.Transcript{otherExport}, time.Now())
+	if err != nil || len(res.Imported) != 1 {
+		t.Fatalf("importing a different answer gave %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,157 @@
+package instructions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"arcane/internal/config"
+)
+
+// FileNames are the instruction files looked for in each directory, in order
+var FileNames = []string{"ARCANE.md", "AGENTS.md"}
+
+// maxFileBytes caps how much of one file goes into the system prompt
+const maxFileBytes = 32 * 1024
+
+// File is an instruction file found on disk
+type File struct {
+	Path      string
+	Content   string
+	Truncated bool
+	ModTime   time.Time
+	Size      int64
+}
+
+// Discover returns the instruction files that apply in workingDir: the
+// user-level ARCANE.md in the config dir, then files from the repo root (or
+// workingDir outside a repo) down to workingDir. More specific files come last.
+func Discover(workingDir, gitRoot string) ([]File, error) {
+	var dirs []string
+	if dir, err := config.Dir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, projectDirs(workingDir, gitRoot)...)
+
+	var files []File
+	seen := map[string]bool{}
+	for i, dir := range dirs {
+		for _, name := range FileNames {
+			if i == 0 && name != FileNames[0] {
+				continue // only ARCANE.md at the user level
+			}
+			path := filepath.Join(dir, name)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			f, ok, err := read(path)
+			if err != nil {
+				return files, err
+			}
+			if ok {
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// projectDirs lists directories from root down to workingDir
+func projectDirs(workingDir, gitRoot string) []string {
+	if workingDir == "" {
+		return nil
+	}
+	dir := filepath.Clean(workingDir)
+	root := filepath.Clean(gitRoot)
+	if gitRoot == "" || !strings.HasPrefix(dir+string(filepath.Separator), root+string(filepath.Separator)) {
+		return []string{dir}
+	}
+	var dirs []string
+	for {
+		dirs = append([]string{dir}, dirs...)
+		if dir == root {
+			return dirs
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs
+		}
+		dir = parent
+	}
+}
+
+func read(path string) (File, bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return File{}, false, nil
+	}
+	if err != nil {
+		return File{}, false, err
+	}
+	if info.IsDir() {
+		return File{}, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, false, err
+	}
+	f := File{Path: path, ModTime: info.ModTime(), Size: info.Size()}
+	if len(data) > maxFileBytes {
+		data = data[:maxFileBytes]
+		f.Truncated = true
+	}
+	f.Content = strings.TrimSpace(string(data))
+	return f, f.Content != "", nil
+}
+
+// Stamp summarises the files' paths, sizes and modification times, so a
+// change on disk can be detected cheaply
+func Stamp(files []File) string {
+	var sb strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&sb, "%s|%d|%d\n", f.Path, f.Size, f.ModTime.UnixNano())
+	}
+	return sb.String()
+}
+
+// Strip removes the section added by Prompt from a system prompt. Chats used
+// to store their prompt with the instructions of the time included.
+func Strip(prompt string) string {
+	base, _, _ := strings.Cut(prompt, promptHeader)
+	return base
+}
+
+// DisplayPath shortens a file path relative to base (usually the project root)
+// or the home directory
+func DisplayPath(path, base string) string {
+	if rel, err := filepath.Rel(base, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// promptHeader starts the section added by Prompt
+const promptHeader = "\n\n# Instructions\n\n" +
+	"The user and project provide the following instructions. Follow them. When they conflict, later files are more specific and take precedence.\n"
+
+// Prompt renders the files as a system prompt section, or "" when there are none
+func Prompt(files []File, base string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(promptHeader)
+	for _, f := range files {
+		fmt.Fprintf(&sb, "\n## %s\n\n%s\n", DisplayPath(f.Path, base), f.Content)
+		if f.Truncated {
+			sb.WriteString("\n(truncated)\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,15 @@
+package instructions
+
+import "testing"
+
+func TestStrip(t *testing.T) {
+	files := []File{{Path: "/repo/ARCANE.md", Content: "Use tabs.\n\n# Instructions\n\nNested heading."}}
+	for _, base := range []string{"You are helpful.", "", "Persona with\n\n# Instructions\n\nof its own."} {
+		if got := Strip(base + Prompt(files, "/repo")); got != base {
+			t.Errorf("Strip(%q + instructions) = %q", base, got)
+		}
+	}
+	if got := Strip("No instructions here."); got != "No instructions here." {
+		t.Errorf("Strip changed a prompt without instructions: %q", got)
+	}
+}
@@ -12,6 +12,7 @@ const (
 	RoleUser      = "user"
 	RoleAssistant = "assistant"
 	RoleTool      = "tool"
+	RoleEvent     = "event" // Transcript-only note such as a mode switch; never sent to the model
 )
 
 type AIModel struct {
@@ -24,14 +25,51 @@ type AIModel struct {
 
 type ChatListItem struct {
 	ID             int64
+	CreatedAtUnix  int64
 	UpdatedAtUnix  int64
 	LastUserPrompt string
 	ModelID        string
+	Mode           AppMode // Mode used for the latest prompt
+	WorkingDir     string  // Directory the chat was started in
+	GitRoot        string  // Repository root containing WorkingDir, if any
+	SystemPrompt   string  // Mode or persona prompt used for the latest prompt, without instruction files
+	Persona        string  // Name of the active persona, empty for the default
+}
+
+// ChatSearchHit is a chat matched by a history search
+type ChatSearchHit struct {
+	ChatListItem
+	MessageID int64  // Newest matching message
+	Role      string //
+	Content   string //
+	Matches   int    // Number of matching messages in the chat
 }
 
 type DBMessage struct {
-	Role    string
-	Content string
+	ID            int64
+	ParentID      int64 // 0 for the first message of a branch
+	Role          string
+	Content       string
+	CreatedAtUnix int64
+
+	// Assistant metadata
+	ModelID          string     // Model that produced the answer
+	PromptTokens     int64      // Usage for the whole turn, including tool rounds
+	CompletionTokens int64      //
+	ToolCalls        []ToolCall // Tools run while producing the answer
+	Reasoning        string     // Thinking the model streamed before the answer
+
+	// Position among messages sharing ParentID (set when loading a branch)
+	SiblingIndex int
+	SiblingCount int
+}
+
+// ToolCall is a tool invocation recorded with an assistant message
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Summary   string `json:"summary,omitempty"`
+	Result    string `json:"result,omitempty"`
 }
 
 // ToolAction represents a completed tool action for display
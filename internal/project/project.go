@@ -0,0 +1,44 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// GitRoot returns the root of the git repository containing dir, or "" when
+// dir is not inside one
+func GitRoot(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	dir = filepath.Clean(dir)
+	for {
+		// .git is a directory in a normal checkout and a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Key identifies the project a chat belongs to: its git root, or the working
+// directory outside a repository
+func Key(workingDir, gitRoot string) string {
+	if gitRoot != "" {
+		return gitRoot
+	}
+	return workingDir
+}
+
+// Name is a short display name for a project
+func Name(workingDir, gitRoot string) string {
+	key := Key(workingDir, gitRoot)
+	if key == "" {
+		return ""
+	}
+	return filepath.Base(key)
+}
@@ -0,0 +1,216 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"arcane/internal/config"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// Policy describes how many times and how long to wait between attempts
+type Policy struct {
+	MaxAttempts int           // Attempts per model, including the first
+	BaseDelay   time.Duration // Delay before the first retry
+	MaxDelay    time.Duration // Upper bound for a single delay
+}
+
+// Attempt describes an upcoming retry, reported before sleeping
+type Attempt struct {
+	Model    string        // Model the next attempt will use
+	Number   int           // 1-based attempt number on that model
+	Delay    time.Duration // How long we wait before the attempt
+	Err      error         // Error that triggered the retry
+	Failover bool          // True when switching to a fallback model
+}
+
+// PolicyFromConfig converts the user config into a Policy
+func PolicyFromConfig(c config.RetryConfig) Policy {
+	return Policy{
+		MaxAttempts: c.MaxAttempts,
+		BaseDelay:   time.Duration(c.BaseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(c.MaxDelayMs) * time.Millisecond,
+	}
+}
+
+// Backoff returns the jittered exponential delay before retry n (1-based)
+func (p Policy) Backoff(n int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// Full jitter on the upper half keeps retries spread out without collapsing to zero
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// Classify reports whether err is transient and any server-requested delay
+func Classify(err error) (retryable bool, retryAfter time.Duration) {
+	if err == nil {
+		return false, 0
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests,
+			apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode == http.StatusConflict,
+			apiErr.StatusCode >= 500:
+			return true, RetryAfter(apiErr.Response)
+		}
+		return false, 0
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true, 0
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, 0
+	}
+
+	// Stream errors arrive as plain errors carrying the provider's message
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"rate limit", "overloaded", "timeout", "temporarily unavailable", "internal server error", "bad gateway"} {
+		if strings.Contains(msg, s) {
+			return true, 0
+		}
+	}
+	return false, 0
+}
+
+// Describe returns a short human-readable reason for a failed attempt
+func Describe(err error) string {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("%d %s", apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	if len(msg) > 40 {
+		msg = msg[:40] + "…"
+	}
+	return msg
+}
+
+// RetryAfter parses Retry-After-Ms / Retry-After from a response
+func RetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	if v := resp.Header.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// Do runs fn against each model in turn, retrying transient errors with backoff.
+// A Retry-After longer than MaxDelay moves on to the next model instead.
+// onRetry is called before every wait so callers can surface a status.
+// It returns the model that finally succeeded.
+func Do(ctx context.Context, p Policy, modelIDs []string, fn func(ctx context.Context, model string) error, onRetry func(Attempt)) (string, error) {
+	if len(modelIDs) == 0 {
+		return "", errors.New("retry: no model to call")
+	}
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for mi, model := range modelIDs {
+		for n := 1; n <= attempts; n++ {
+			if mi > 0 || n > 1 {
+				var delay time.Duration
+				if n == 1 {
+					// Failing over: any error other than cancellation is worth
+					// trying on another model, and there is no need to back off
+					if ctx.Err() != nil {
+						return model, lastErr
+					}
+				} else {
+					retryable, after := Classify(lastErr)
+					if !retryable {
+						break
+					}
+					if p.MaxDelay > 0 && after > p.MaxDelay {
+						// The server asks for a longer wait than allowed;
+						// another model may answer now
+						break
+					}
+					delay = max(p.Backoff(n-1), after)
+				}
+				if onRetry != nil {
+					onRetry(Attempt{Model: model, Number: n, Delay: delay, Err: lastErr, Failover: n == 1})
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return model, err
+				}
+			}
+
+			lastErr = fn(ctx, model)
+			if lastErr == nil {
+				return model, nil
+			}
+			if ctx.Err() != nil {
+				return model, lastErr
+			}
+		}
+	}
+	return modelIDs[len(modelIDs)-1], lastErr
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,365 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"arcane/internal/retry"
+	"arcane/internal/retry/retrytest"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// fast retries without waiting, so tests only wait where a server asks them to
+var fast = retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+// complete returns a call that sends one non-streaming request to the server
+func complete(client openai.Client, answer *string) func(context.Context, string) error {
+	return func(ctx context.Context, model string) error {
+		resp, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model:    model,
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi")},
+		})
+		if err != nil {
+			return err
+		}
+		*answer = resp.Choices[0].Message.Content
+		return nil
+	}
+}
+
+func apiError(status int, header http.Header) error {
+	return &openai.Error{StatusCode: status, Response: &http.Response{StatusCode: status, Header: header}}
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	p := fast
+	p.MaxDelay = 2 * time.Second
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "1"}, Body: retrytest.Error("rate limited")},
+		retrytest.Reply{Body: retrytest.Completion("done")},
+	)
+
+	var attempts []retry.Attempt
+	var answer string
+	model, err := retry.Do(context.Background(), p, []string{"m1"}, complete(srv.Client(), &answer),
+		func(a retry.Attempt) { attempts = append(attempts, a) })
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if model != "m1" || answer != "done" {
+		t.Errorf("got model %q answer %q, want m1 done", model, answer)
+	}
+	if len(attempts) != 1 {
+		t.Fatalf("got %d retries, want 1", len(attempts))
+	}
+	if a := attempts[0]; a.Delay < time.Second || a.Number != 2 || a.Failover {
+		t.Errorf("retry = %+v, want attempt 2 on the same model after at least 1s", a)
+	}
+	calls := srv.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d requests, want 2", len(calls))
+	}
+	if gap := calls[1].At.Sub(calls[0].At); gap < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", gap)
+	}
+}
+
+func TestDoRetriesServerErrors(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusBadGateway, Body: retrytest.Error("bad gateway")},
+		retrytest.Reply{Status: http.StatusServiceUnavailable, Body: retrytest.Error("unavailable")},
+		retrytest.Reply{Body: retrytest.Completion("done")},
+	)
+
+	retries := 0
+	var answer string
+	_, err := retry.Do(context.Background(), fast, []string{"m1"}, complete(srv.Client(), &answer),
+		func(retry.Attempt) { retries++ })
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if answer != "done" || retries != 2 || len(srv.Calls()) != 3 {
+		t.Errorf("got answer %q after %d retries and %d requests, want done after 2 and 3", answer, retries, len(srv.Calls()))
+	}
+}
+
+func TestDoRetriesBrokenStream(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Body: retrytest.BrokenStream("Provider overloaded", "Hal", "f an ")},
+		retrytest.Reply{Body: retrytest.Stream("Whole ", "answer")},
+	)
+	client := srv.Client()
+
+	var content string
+	_, err := retry.Do(context.Background(), fast, []string{"m1"}, func(ctx context.Context, model string) error {
+		var acc openai.ChatCompletionAccumulator
+		stream := client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+			Model:    model,
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi")},
+		})
+		defer stream.Close()
+		for stream.Next() {
+			acc.AddChunk(stream.Current())
+		}
+		if err := stream.Err(); err != nil {
+			return err
+		}
+		content = acc.Choices[0].Message.Content
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if content != "Whole answer" {
+		t.Errorf("got %q, want only the second stream", content)
+	}
+	if n := len(srv.Calls()); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestDoFailsOver(t *testing.T) {
+	p := fast
+	p.MaxAttempts = 2
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusServiceUnavailable, Body: retrytest.Error("down")},
+		retrytest.Reply{Status: http.StatusServiceUnavailable, Body: retrytest.Error("down")},
+		retrytest.Reply{Body: retrytest.Completion("from fallback")},
+	)
+
+	var attempts []retry.Attempt
+	var answer string
+	model, err := retry.Do(context.Background(), p, []string{"primary", "fallback"}, complete(srv.Client(), &answer),
+		func(a retry.Attempt) { attempts = append(attempts, a) })
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if model != "fallback" || answer != "from fallback" {
+		t.Errorf("got model %q answer %q, want the fallback's", model, answer)
+	}
+	if got := fmt.Sprint(srv.Models()); got != "[primary primary fallback]" {
+		t.Errorf("requests went to %s", got)
+	}
+	last := attempts[len(attempts)-1]
+	if !last.Failover || last.Model != "fallback" || last.Delay != 0 {
+		t.Errorf("last retry = %+v, want an immediate failover to fallback", last)
+	}
+}
+
+func TestDoFailsOverOnLongRetryAfter(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "3600"}, Body: retrytest.Error("rate limited")},
+		retrytest.Reply{Body: retrytest.Completion("from fallback")},
+	)
+
+	var attempts []retry.Attempt
+	var answer string
+	start := time.Now()
+	model, err := retry.Do(context.Background(), fast, []string{"primary", "fallback"}, complete(srv.Client(), &answer),
+		func(a retry.Attempt) { attempts = append(attempts, a) })
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if model != "fallback" || fmt.Sprint(srv.Models()) != "[primary fallback]" {
+		t.Errorf("got model %q after requests %v, want straight to the fallback", model, srv.Models())
+	}
+	if len(attempts) != 1 || !attempts[0].Failover || attempts[0].Delay != 0 {
+		t.Errorf("retries = %+v, want one immediate failover", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want no wait for the Retry-After", elapsed)
+	}
+}
+
+func TestDoFailsOverOnClientErrorWithoutRetrying(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusBadRequest, Body: retrytest.Error("model does not support tools")},
+		retrytest.Reply{Body: retrytest.Completion("ok")},
+	)
+
+	var answer string
+	model, err := retry.Do(context.Background(), fast, []string{"primary", "fallback"}, complete(srv.Client(), &answer), nil)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if model != "fallback" || fmt.Sprint(srv.Models()) != "[primary fallback]" {
+		t.Errorf("got model %q after requests %v, want one try on each", model, srv.Models())
+	}
+}
+
+func TestDoReturnsLastError(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusUnauthorized, Body: retrytest.Error("bad key")},
+	)
+
+	var answer string
+	_, err := retry.Do(context.Background(), fast, []string{"m1"}, complete(srv.Client(), &answer), nil)
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got %v, want the 401", err)
+	}
+	if n := len(srv.Calls()); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestDoStopsWhenCancelledDuringBackoff(t *testing.T) {
+	p := fast
+	p.MaxDelay = time.Minute
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "30"}, Body: retrytest.Error("slow down")},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	var answer string
+	_, err := retry.Do(ctx, p, []string{"m1", "m2"}, complete(srv.Client(), &answer),
+		func(retry.Attempt) { time.AfterFunc(20*time.Millisecond, cancel) })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %v to notice the cancellation", elapsed)
+	}
+	if n := len(srv.Calls()); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestDoStopsWhenCancelledDuringCall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	_, err := retry.Do(ctx, fast, []string{"m1", "m2"}, func(ctx context.Context, _ string) error {
+		calls++
+		cancel()
+		return fmt.Errorf("read: %w", ctx.Err())
+	}, nil)
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("got %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
+
+func TestDoWithoutModels(t *testing.T) {
+	if _, err := retry.Do(context.Background(), fast, nil, nil, nil); err == nil {
+		t.Error("want an error with no models")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := retry.Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for _, tc := range []struct {
+		n    int
+		want time.Duration // Upper bound; jitter keeps the delay in its upper half
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	} {
+		seen := map[time.Duration]bool{}
+		for range 50 {
+			d := p.Backoff(tc.n)
+			if d < tc.want/2 || d > tc.want {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", tc.n, d, tc.want/2, tc.want)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("Backoff(%d) always returned the same delay; want jitter", tc.n)
+		}
+	}
+
+	if d := (retry.Policy{}).Backoff(3); d != 0 {
+		t.Errorf("Backoff without a base delay = %v, want 0", d)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		retryable bool
+		after     time.Duration
+	}{
+		{"nil", nil, false, 0},
+		{"cancelled", fmt.Errorf("post: %w", context.Canceled), false, 0},
+		{"deadline", context.DeadlineExceeded, false, 0},
+		{"429", apiError(429, nil), true, 0},
+		{"429 with Retry-After", apiError(429, http.Header{"Retry-After": {"7"}}), true, 7 * time.Second},
+		{"408", apiError(408, nil), true, 0},
+		{"409", apiError(409, nil), true, 0},
+		{"500", apiError(500, nil), true, 0},
+		{"503 with Retry-After-Ms", apiError(503, http.Header{"Retry-After-Ms": {"250"}}), true, 250 * time.Millisecond},
+		{"400", apiError(400, nil), false, 0},
+		{"401", apiError(401, nil), false, 0},
+		{"404", apiError(404, nil), false, 0},
+		{"unexpected EOF", fmt.Errorf("stream: %w", io.ErrUnexpectedEOF), true, 0},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, true, 0},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true, 0},
+		{"network timeout", timeoutError{}, true, 0},
+		{"stream rate limit", errors.New("received error while streaming: Rate limit exceeded"), true, 0},
+		{"stream overloaded", errors.New("received error while streaming: Provider overloaded"), true, 0},
+		{"other", errors.New("invalid model ID"), false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			retryable, after := retry.Classify(tc.err)
+			if retryable != tc.retryable || after != tc.after {
+				t.Errorf("Classify = %v, %v; want %v, %v", retryable, after, tc.retryable, tc.after)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{"fractional seconds", http.Header{"Retry-After": {"1.5"}}, 1500 * time.Millisecond},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"120"}}, 120 * time.Millisecond},
+		{"milliseconds win", http.Header{"Retry-After-Ms": {"120"}, "Retry-After": {"3"}}, 120 * time.Millisecond},
+		{"bad milliseconds fall back", http.Header{"Retry-After-Ms": {"soon"}, "Retry-After": {"3"}}, 3 * time.Second},
+		{"zero", http.Header{"Retry-After": {"0"}}, 0},
+		{"negative", http.Header{"Retry-After": {"-5"}}, 0},
+		{"past date", http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0},
+		{"garbage", http.Header{"Retry-After": {"later"}}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retry.RetryAfter(&http.Response{Header: tc.header}); got != tc.want {
+				t.Errorf("RetryAfter = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if got := retry.RetryAfter(nil); got != 0 {
+		t.Errorf("RetryAfter(nil) = %v, want 0", got)
+	}
+
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if got := retry.RetryAfter(&http.Response{Header: http.Header{"Retry-After": {future}}}); got < 8*time.Second || got > 10*time.Second {
+		t.Errorf("RetryAfter(%s) = %v, want about 10s", future, got)
+	}
+}
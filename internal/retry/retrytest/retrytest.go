@@ -0,0 +1,138 @@
+// Package retrytest provides a fake chat completions server that answers
+// with scripted replies, for testing retries and failover.
+package retrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// Reply is one scripted response
+type Reply struct {
+	Status int               // Defaults to 200
+	Header map[string]string // Extra response headers
+	Body   string
+}
+
+// Call records a request the server received
+type Call struct {
+	Model  string
+	Stream bool
+	At     time.Time
+}
+
+// Server answers POST /chat/completions with its replies in order. Requests
+// beyond the script get a 500.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	replies []Reply
+	calls   []Call
+}
+
+// NewServer starts a server that is closed when the test ends
+func NewServer(t *testing.T, replies ...Reply) *Server {
+	t.Helper()
+	s := &Server{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Model: req.Model, Stream: req.Stream, At: time.Now()})
+	reply := Reply{Status: http.StatusInternalServerError, Body: Error("script exhausted")}
+	if len(s.replies) > 0 {
+		reply, s.replies = s.replies[0], s.replies[1:]
+	}
+	s.mu.Unlock()
+
+	contentType := "application/json"
+	if strings.HasPrefix(reply.Body, "data:") {
+		contentType = "text/event-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	for k, v := range reply.Header {
+		w.Header().Set(k, v)
+	}
+	if reply.Status == 0 {
+		reply.Status = http.StatusOK
+	}
+	w.WriteHeader(reply.Status)
+	_, _ = w.Write([]byte(reply.Body))
+}
+
+// Calls returns the requests received so far
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Models returns the model of each request received so far
+func (s *Server) Models() []string {
+	var ids []string
+	for _, c := range s.Calls() {
+		ids = append(ids, c.Model)
+	}
+	return ids
+}
+
+// Client returns an API client for the server with the SDK's own retries
+// turned off, as the app does
+func (s *Server) Client() openai.Client {
+	return openai.NewClient(
+		option.WithBaseURL(s.URL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+	)
+}
+
+// Error is an API error body
+func Error(message string) string {
+	return fmt.Sprintf(`{"error":{"message":%q}}`, message)
+}
+
+// Completion is a non-streaming answer
+func Completion(content string) string {
+	return fmt.Sprintf(`{"id":"c","object":"chat.completion","created":1,"model":"m",`+
+		`"choices":[{"index":0,"message":{"role":"assistant","content":%q},"finish_reason":"stop"}],`+
+		`"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`, content)
+}
+
+// Stream is a streamed answer sent as one delta per part
+func Stream(parts ...string) string {
+	return streamChunks(parts) + "data: [DONE]\n\n"
+}
+
+// BrokenStream sends the parts, then an error event in place of the end of
+// the stream, as providers do when they fail partway through an answer
+func BrokenStream(message string, parts ...string) string {
+	return streamChunks(parts) + fmt.Sprintf("data: %s\n\n", Error(message))
+}
+
+func streamChunks(parts []string) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		fmt.Fprintf(&sb, `data: {"id":"c","object":"chat.completion.chunk","created":1,"model":"m",`+
+			`"choices":[{"index":0,"delta":{"role":"assistant","content":%q},"finish_reason":null}]}`+"\n\n", p)
+	}
+	return sb.String()
+}
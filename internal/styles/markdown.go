@@ -0,0 +1,78 @@
+package styles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/glamour/ansi"
+	glamourstyles "github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MarkdownStyle returns the glamour style for the theme. A theme that names
+// a style gets that style; otherwise glamour's dark or light style is
+// recolored so answers match the rest of the UI. If the named style cannot
+// be loaded, the recolored one is returned with the error.
+func (t Theme) MarkdownStyle() (ansi.StyleConfig, error) {
+	if t.Markdown == "" {
+		return t.derivedMarkdownStyle(), nil
+	}
+	s, err := namedMarkdownStyle(t.Markdown)
+	if err != nil {
+		return t.derivedMarkdownStyle(), err
+	}
+	return s, nil
+}
+
+func (t Theme) derivedMarkdownStyle() ansi.StyleConfig {
+	s := glamourstyles.LightStyleConfig
+	if t.Dark {
+		s = glamourstyles.DarkStyleConfig
+	}
+	color := func(c lipgloss.Color) *string {
+		v := string(c)
+		return &v
+	}
+	s.Document.Color = color(t.Text)
+	s.BlockQuote.Color = color(t.TextSecondary)
+	s.Heading.Color = color(t.Secondary)
+	s.H1.Color = color(t.OnColor)
+	s.H1.BackgroundColor = color(t.Primary)
+	s.H6.Color = color(t.TextMuted)
+	s.HorizontalRule.Color = color(t.Border)
+	s.Link.Color = color(t.Secondary)
+	s.LinkText.Color = color(t.Accent)
+	s.Image.Color = color(t.Accent)
+	s.ImageText.Color = color(t.TextMuted)
+	s.Code.Color = color(t.Highlight)
+	s.Code.BackgroundColor = color(t.BgElevated)
+	s.CodeBlock.Color = color(t.TextSecondary)
+	return s
+}
+
+// namedMarkdownStyle loads a built-in glamour style or a glamour JSON file.
+// Relative paths are resolved against ThemeDir.
+func namedMarkdownStyle(name string) (ansi.StyleConfig, error) {
+	if s, ok := glamourstyles.DefaultStyles[name]; ok {
+		return *s, nil
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		dir, err := ThemeDir()
+		if err != nil {
+			return ansi.StyleConfig{}, err
+		}
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ansi.StyleConfig{}, fmt.Errorf("markdown style: %w", err)
+	}
+	var s ansi.StyleConfig
+	if err := json.Unmarshal(data, &s); err != nil {
+		return ansi.StyleConfig{}, fmt.Errorf("markdown style %s: %w", filepath.Base(path), err)
+	}
+	return s, nil
+}
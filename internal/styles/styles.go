@@ -6,7 +6,7 @@ var (
 	ContentWidth = 54
 )
 
-// Noir Rose palette
+// Noir Rose palette, used by DarkTheme and the HTML export
 const (
 	Rose   = "#F43F5E"
 	Violet = "#8B5CF6"
@@ -27,128 +27,187 @@ const (
 	Success  = "#10B981"
 )
 
+// Styles are rebuilt from the theme by Apply, so read them at render time
+// rather than keeping copies
 var (
+	TitleStyle           lipgloss.Style
+	InfoStyle            func(...string) string
+	UserLabelStyle       lipgloss.Style
+	UserMsgStyle         lipgloss.Style
+	AiLabelStyle         lipgloss.Style
+	AiMsgStyle           lipgloss.Style
+	ErrorStyle           lipgloss.Style
+	WarningStyle         lipgloss.Style
+	ToolActionStyle      lipgloss.Style
+	ToolIconStyle        lipgloss.Style
+	ToolNameStyle        lipgloss.Style
+	ToolDetailStyle      lipgloss.Style
+	ReasoningStyle       lipgloss.Style
+	BranchIndicatorStyle lipgloss.Style
+	TabStyle             lipgloss.Style
+	ActiveTabStyle       lipgloss.Style
+	FocusMarkerStyle     lipgloss.Style
+	InputBoxStyle        lipgloss.Style
+	WelcomeArtStyle      lipgloss.Style
+	WelcomeSubtitleStyle lipgloss.Style
+	ModalStyle           lipgloss.Style
+	ModalTitleStyle      lipgloss.Style
+	ModalItemStyle       lipgloss.Style
+	ModalHeaderStyle     lipgloss.Style
+	ModalSelectedStyle   lipgloss.Style
+	ModelNameStyle       lipgloss.Style
+	ProviderStyle        lipgloss.Style
+	DescStyle            lipgloss.Style
+	HintColor            lipgloss.Color
+	InputTokenStyle      lipgloss.Style
+	OutputTokenStyle     lipgloss.Style
+)
+
+func init() {
+	Apply(DarkTheme)
+}
+
+// Apply makes t the current theme and rebuilds the styles from it
+func Apply(t Theme) {
+	CurrentTheme = t
+
 	TitleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color(Rose)).
-			Italic(true).
-			Padding(0, 1)
+		Bold(true).
+		Foreground(t.Primary).
+		Italic(true).
+		Padding(0, 1)
 
 	InfoStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(TextMuted)).
-			Render
+		Foreground(t.TextMuted).
+		Render
 
 	UserLabelStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#0E1525")).
-			Background(lipgloss.Color(Cyan)).
-			Bold(true).
-			Padding(0, 1).
-			MarginRight(1)
+		Foreground(t.OnColor).
+		Background(t.Secondary).
+		Bold(true).
+		Padding(0, 1).
+		MarginRight(1)
 
 	UserMsgStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.AdaptiveColor{Light: "#1E293B", Dark: TextPrimary}).
-			PaddingLeft(2).
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color(Cyan))
+		Foreground(t.Text).
+		PaddingLeft(2).
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(t.Secondary)
 
 	AiLabelStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#F1F5F9")).
-			Background(lipgloss.Color(Violet)).
-			Bold(true).
-			Padding(0, 1).
-			MarginRight(1)
+		Foreground(t.OnAccent).
+		Background(t.Accent).
+		Bold(true).
+		Padding(0, 1).
+		MarginRight(1)
 
 	AiMsgStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.AdaptiveColor{Light: "#1E293B", Dark: TextPrimary}).
-			PaddingTop(1).
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color(Violet))
+		Foreground(t.Text).
+		PaddingTop(1).
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(t.Accent)
 
 	ErrorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ErrRed)).
-			Bold(true)
+		Foreground(t.Error).
+		Bold(true)
+
+	WarningStyle = lipgloss.NewStyle().
+		Foreground(t.Warning)
 
 	ToolActionStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(TextDim)).
-			PaddingLeft(2)
+		Foreground(t.TextDim).
+		PaddingLeft(2)
 
 	ToolIconStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(Amber)).
-			Bold(true)
+		Foreground(t.Highlight).
+		Bold(true)
 
 	ToolNameStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FCD34D")).
-			Bold(true)
+		Foreground(t.ToolName).
+		Bold(true)
 
 	ToolDetailStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(TextMuted))
+		Foreground(t.TextMuted)
+
+	ReasoningStyle = lipgloss.NewStyle().
+		Foreground(t.TextDim).
+		Italic(true)
+
+	BranchIndicatorStyle = lipgloss.NewStyle().
+		Foreground(t.TextDim).
+		Italic(true).
+		PaddingLeft(2)
+
+	TabStyle = lipgloss.NewStyle().
+		Foreground(t.TextMuted).
+		Padding(0, 1)
+
+	ActiveTabStyle = lipgloss.NewStyle().
+		Foreground(t.OnAccent).
+		Background(t.Accent).
+		Bold(true).
+		Padding(0, 1)
+
+	FocusMarkerStyle = lipgloss.NewStyle().
+		Foreground(t.Highlight).
+		Bold(true)
 
 	InputBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color(Rose)).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Primary).
+		Padding(0, 1)
 
 	WelcomeArtStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.AdaptiveColor{Light: "#1E293B", Dark: Rose}).
-			Bold(true)
+		Foreground(t.Primary).
+		Bold(true)
 
 	WelcomeSubtitleStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(Violet)).
-				Italic(true)
+		Foreground(t.Accent).
+		Italic(true)
 
 	ModalStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color(Rose)).
-			Padding(1, 2)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Primary).
+		Padding(1, 2)
 
 	ModalTitleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color(Rose)).
-			Width(ContentWidth).
-			MarginBottom(1)
+		Bold(true).
+		Foreground(t.Primary).
+		Width(ContentWidth).
+		MarginBottom(1)
 
 	ModalItemStyle = lipgloss.NewStyle().
-			Padding(0, 1).
-			Width(ContentWidth)
+		Padding(0, 1).
+		Width(ContentWidth)
 
 	ModalHeaderStyle = lipgloss.NewStyle().
-				Bold(true).
-				PaddingLeft(1).
-				Width(ContentWidth)
+		Bold(true).
+		PaddingLeft(1).
+		Width(ContentWidth)
 
 	ModalSelectedStyle = lipgloss.NewStyle().
-				Padding(0, 1).
-				Width(ContentWidth).
-				Background(lipgloss.Color("#312E81")).
-				Foreground(lipgloss.Color(TextPrimary))
+		Padding(0, 1).
+		Width(ContentWidth).
+		Background(t.Selection).
+		Foreground(t.Text)
 
 	ModelNameStyle = lipgloss.NewStyle().
-			Bold(true).
-			MarginRight(1).
-			Foreground(lipgloss.AdaptiveColor{Light: "#1E293B", Dark: TextPrimary})
+		Bold(true).
+		MarginRight(1).
+		Foreground(t.Text)
 
 	ProviderStyle = lipgloss.NewStyle().
-			Italic(true).
-			MarginRight(1)
+		Italic(true).
+		MarginRight(1)
 
 	DescStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(TextMuted)).
-			Width(50)
-
-	HintColor = lipgloss.Color(TextMuted)
+		Foreground(t.TextMuted).
+		Width(50)
 
-	InputTokenStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color(Cyan))
-	OutputTokenStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(Violet))
-)
+	HintColor = t.TextMuted
 
-var ProviderColors = map[string]string{
-	"Gemini":     "#A78BFA",
-	"Xai":        Pink,
-	"Deepseek":   Cyan,
-	"MiniMax":    "#60A5FA",
-	"Perplexity": Amber,
-	"Z.ai":       Success,
-	"OpenAI":     Success,
+	InputTokenStyle = lipgloss.NewStyle().Foreground(t.Secondary)
+	OutputTokenStyle = lipgloss.NewStyle().Foreground(t.Accent)
 }
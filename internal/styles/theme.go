@@ -1,136 +1,446 @@
 package styles
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"arcane/internal/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
-// Theme defines a complete color scheme for the application
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Theme defines a complete color scheme for the application. Themes are
+// loaded from JSON, so every field has a tag; colors are "#RRGGBB", "#RGB"
+// or an ANSI 256 color number.
 type Theme struct {
+	Name string `json:"-"`
+	Path string `json:"-"`    // File the theme was loaded from, empty for built-in themes
+	Dark bool   `json:"dark"` // Picks the dark or light base for the Markdown style
+
 	// Core colors
-	Primary   lipgloss.Color
-	Secondary lipgloss.Color
-	Accent    lipgloss.Color
+	Primary   lipgloss.Color `json:"primary"`   // Title, input and modal borders
+	Secondary lipgloss.Color `json:"secondary"` // Prompts, user messages, current items
+	Accent    lipgloss.Color `json:"accent"`    // Answers, the selected tab and command
+	Highlight lipgloss.Color `json:"highlight"` // Key hints, markers, search matches
+	Prompt    lipgloss.Color `json:"prompt"`    // Input prompt and spinner
+	ToolName  lipgloss.Color `json:"tool_name"`
 
 	// Background colors
-	BgBase     lipgloss.Color
-	BgSurface  lipgloss.Color
-	BgElevated lipgloss.Color
+	BgBase     lipgloss.Color `json:"bg_base"`
+	BgSurface  lipgloss.Color `json:"bg_surface"`
+	BgElevated lipgloss.Color `json:"bg_elevated"` // Popups above the input
+	Selection  lipgloss.Color `json:"selection"`   // Selected row in modals
 
 	// Text colors
-	TextPrimary_   lipgloss.Color
-	TextSecondary_ lipgloss.Color
-	TextMuted_     lipgloss.Color
+	Text          lipgloss.Color `json:"text"`
+	TextSecondary lipgloss.Color `json:"text_secondary"`
+	TextMuted     lipgloss.Color `json:"text_muted"`
+	TextDim       lipgloss.Color `json:"text_dim"`
+	OnColor       lipgloss.Color `json:"on_color"`  // Text on primary, secondary and highlight backgrounds
+	OnAccent      lipgloss.Color `json:"on_accent"` // Text on accent backgrounds
 
 	// Semantic colors
-	Success_ lipgloss.Color
-	Warning  lipgloss.Color
-	Error_   lipgloss.Color
-	Info     lipgloss.Color
+	Success lipgloss.Color `json:"success"`
+	Warning lipgloss.Color `json:"warning"`
+	Error   lipgloss.Color `json:"error"`
+	Info    lipgloss.Color `json:"info"`
 
 	// UI element colors
-	Border  lipgloss.Color
-	Divider lipgloss.Color
+	Border  lipgloss.Color `json:"border"`
+	Divider lipgloss.Color `json:"divider"`
 
 	// Mode-specific
-	ModeChat  lipgloss.Color
-	ModeAgent lipgloss.Color
+	ModeChat  lipgloss.Color `json:"mode_chat"`
+	ModeAgent lipgloss.Color `json:"mode_agent"`
+
+	// Providers colors provider headers in the model selector; providers
+	// not listed get a color from the theme
+	Providers map[string]lipgloss.Color `json:"providers"`
+
+	// Markdown names a glamour style ("dark", "light", "dracula",
+	// "tokyo-night", "pink") or a glamour JSON file. Empty derives a style
+	// from the theme colors.
+	Markdown string `json:"markdown"`
 }
 
 // DarkTheme — Noir Rose palette
 var DarkTheme = Theme{
+	Name:      "dark",
+	Dark:      true,
 	Primary:   lipgloss.Color(Rose),
 	Secondary: lipgloss.Color(Cyan),
 	Accent:    lipgloss.Color(Violet),
+	Highlight: lipgloss.Color(Amber),
+	Prompt:    lipgloss.Color("#B39DDB"),
+	ToolName:  lipgloss.Color("#FCD34D"),
 
 	BgBase:     lipgloss.Color("#080C14"),
 	BgSurface:  lipgloss.Color("#0D1117"),
 	BgElevated: lipgloss.Color(BgDeep),
+	Selection:  lipgloss.Color("#312E81"),
 
-	TextPrimary_:   lipgloss.Color(TextPrimary),
-	TextSecondary_: lipgloss.Color(TextSecondary),
-	TextMuted_:     lipgloss.Color(TextMuted),
+	Text:          lipgloss.Color(TextPrimary),
+	TextSecondary: lipgloss.Color(TextSecondary),
+	TextMuted:     lipgloss.Color(TextMuted),
+	TextDim:       lipgloss.Color(TextDim),
+	OnColor:       lipgloss.Color("#0E1525"),
+	OnAccent:      lipgloss.Color(TextPrimary),
 
-	Success_: lipgloss.Color(Success),
-	Warning:  lipgloss.Color(Amber),
-	Error_:   lipgloss.Color(ErrRed),
-	Info:     lipgloss.Color(Cyan),
+	Success: lipgloss.Color(Success),
+	Warning: lipgloss.Color(ErrAmber),
+	Error:   lipgloss.Color(ErrRed),
+	Info:    lipgloss.Color(Cyan),
 
 	Border:  lipgloss.Color(BorderDark),
-	Divider: lipgloss.Color("#111827"),
+	Divider: lipgloss.Color("#334155"),
 
 	ModeChat:  lipgloss.Color(Rose),
 	ModeAgent: lipgloss.Color(Pink),
+
+	Providers: map[string]lipgloss.Color{
+		"Gemini":     lipgloss.Color("#A78BFA"),
+		"Xai":        lipgloss.Color(Pink),
+		"Deepseek":   lipgloss.Color(Cyan),
+		"MiniMax":    lipgloss.Color("#60A5FA"),
+		"Perplexity": lipgloss.Color(Amber),
+		"Z.ai":       lipgloss.Color(Success),
+		"OpenAI":     lipgloss.Color(Success),
+	},
 }
 
 // LightTheme is the light mode color scheme
 var LightTheme = Theme{
+	Name:      "light",
 	Primary:   lipgloss.Color("#E11D48"),
 	Secondary: lipgloss.Color("#0891B2"),
 	Accent:    lipgloss.Color("#7C3AED"),
+	Highlight: lipgloss.Color("#D97706"),
+	Prompt:    lipgloss.Color("#7C3AED"),
+	ToolName:  lipgloss.Color("#B45309"),
 
 	BgBase:     lipgloss.Color("#FAFAFA"),
 	BgSurface:  lipgloss.Color("#FFFFFF"),
 	BgElevated: lipgloss.Color("#F4F4F5"),
+	Selection:  lipgloss.Color("#DDD6FE"),
 
-	TextPrimary_:   lipgloss.Color("#0F172A"),
-	TextSecondary_: lipgloss.Color("#475569"),
-	TextMuted_:     lipgloss.Color("#94A3B8"),
+	Text:          lipgloss.Color("#0F172A"),
+	TextSecondary: lipgloss.Color("#475569"),
+	TextMuted:     lipgloss.Color("#64748B"),
+	TextDim:       lipgloss.Color("#94A3B8"),
+	OnColor:       lipgloss.Color("#FFFFFF"),
+	OnAccent:      lipgloss.Color("#FFFFFF"),
 
-	Success_: lipgloss.Color("#10B981"),
-	Warning:  lipgloss.Color("#F59E0B"),
-	Error_:   lipgloss.Color("#EF4444"),
-	Info:     lipgloss.Color("#0891B2"),
+	Success: lipgloss.Color("#059669"),
+	Warning: lipgloss.Color("#D97706"),
+	Error:   lipgloss.Color("#DC2626"),
+	Info:    lipgloss.Color("#0891B2"),
 
-	Border:  lipgloss.Color("#E2E8F0"),
-	Divider: lipgloss.Color("#F1F5F9"),
+	Border:  lipgloss.Color("#CBD5E1"),
+	Divider: lipgloss.Color("#E2E8F0"),
 
 	ModeChat:  lipgloss.Color("#E11D48"),
 	ModeAgent: lipgloss.Color("#7C3AED"),
 }
 
-// CurrentTheme holds the active theme (set at runtime based on terminal)
+// DraculaTheme follows the Dracula palette
+var DraculaTheme = Theme{
+	Name:      "dracula",
+	Dark:      true,
+	Primary:   lipgloss.Color("#FF79C6"),
+	Secondary: lipgloss.Color("#8BE9FD"),
+	Accent:    lipgloss.Color("#BD93F9"),
+	Highlight: lipgloss.Color("#F1FA8C"),
+	Prompt:    lipgloss.Color("#BD93F9"),
+	ToolName:  lipgloss.Color("#FFB86C"),
+
+	BgBase:     lipgloss.Color("#21222C"),
+	BgSurface:  lipgloss.Color("#282A36"),
+	BgElevated: lipgloss.Color("#343746"),
+	Selection:  lipgloss.Color("#44475A"),
+
+	Text:          lipgloss.Color("#F8F8F2"),
+	TextSecondary: lipgloss.Color("#CFCFC2"),
+	TextMuted:     lipgloss.Color("#6272A4"),
+	TextDim:       lipgloss.Color("#565E84"),
+	OnColor:       lipgloss.Color("#282A36"),
+	OnAccent:      lipgloss.Color("#282A36"),
+
+	Success: lipgloss.Color("#50FA7B"),
+	Warning: lipgloss.Color("#FFB86C"),
+	Error:   lipgloss.Color("#FF5555"),
+	Info:    lipgloss.Color("#8BE9FD"),
+
+	Border:  lipgloss.Color("#44475A"),
+	Divider: lipgloss.Color("#44475A"),
+
+	ModeChat:  lipgloss.Color("#FF79C6"),
+	ModeAgent: lipgloss.Color("#BD93F9"),
+
+	Markdown: "dracula",
+}
+
+// NordTheme follows the Nord palette
+var NordTheme = Theme{
+	Name:      "nord",
+	Dark:      true,
+	Primary:   lipgloss.Color("#81A1C1"),
+	Secondary: lipgloss.Color("#88C0D0"),
+	Accent:    lipgloss.Color("#B48EAD"),
+	Highlight: lipgloss.Color("#EBCB8B"),
+	Prompt:    lipgloss.Color("#88C0D0"),
+	ToolName:  lipgloss.Color("#EBCB8B"),
+
+	BgBase:     lipgloss.Color("#2E3440"),
+	BgSurface:  lipgloss.Color("#3B4252"),
+	BgElevated: lipgloss.Color("#3B4252"),
+	Selection:  lipgloss.Color("#434C5E"),
+
+	Text:          lipgloss.Color("#ECEFF4"),
+	TextSecondary: lipgloss.Color("#D8DEE9"),
+	TextMuted:     lipgloss.Color("#7B88A1"),
+	TextDim:       lipgloss.Color("#4C566A"),
+	OnColor:       lipgloss.Color("#2E3440"),
+	OnAccent:      lipgloss.Color("#2E3440"),
+
+	Success: lipgloss.Color("#A3BE8C"),
+	Warning: lipgloss.Color("#D08770"),
+	Error:   lipgloss.Color("#BF616A"),
+	Info:    lipgloss.Color("#88C0D0"),
+
+	Border:  lipgloss.Color("#434C5E"),
+	Divider: lipgloss.Color("#4C566A"),
+
+	ModeChat:  lipgloss.Color("#81A1C1"),
+	ModeAgent: lipgloss.Color("#B48EAD"),
+}
+
+// GruvboxTheme follows the Gruvbox dark palette
+var GruvboxTheme = Theme{
+	Name:      "gruvbox",
+	Dark:      true,
+	Primary:   lipgloss.Color("#FE8019"),
+	Secondary: lipgloss.Color("#8EC07C"),
+	Accent:    lipgloss.Color("#D3869B"),
+	Highlight: lipgloss.Color("#FABD2F"),
+	Prompt:    lipgloss.Color("#D3869B"),
+	ToolName:  lipgloss.Color("#FABD2F"),
+
+	BgBase:     lipgloss.Color("#1D2021"),
+	BgSurface:  lipgloss.Color("#282828"),
+	BgElevated: lipgloss.Color("#3C3836"),
+	Selection:  lipgloss.Color("#504945"),
+
+	Text:          lipgloss.Color("#EBDBB2"),
+	TextSecondary: lipgloss.Color("#D5C4A1"),
+	TextMuted:     lipgloss.Color("#928374"),
+	TextDim:       lipgloss.Color("#665C54"),
+	OnColor:       lipgloss.Color("#282828"),
+	OnAccent:      lipgloss.Color("#282828"),
+
+	Success: lipgloss.Color("#B8BB26"),
+	Warning: lipgloss.Color("#FABD2F"),
+	Error:   lipgloss.Color("#FB4934"),
+	Info:    lipgloss.Color("#83A598"),
+
+	Border:  lipgloss.Color("#504945"),
+	Divider: lipgloss.Color("#504945"),
+
+	ModeChat:  lipgloss.Color("#FE8019"),
+	ModeAgent: lipgloss.Color("#D3869B"),
+}
+
+// TokyoNightTheme follows the Tokyo Night palette
+var TokyoNightTheme = Theme{
+	Name:      "tokyo-night",
+	Dark:      true,
+	Primary:   lipgloss.Color("#7AA2F7"),
+	Secondary: lipgloss.Color("#7DCFFF"),
+	Accent:    lipgloss.Color("#BB9AF7"),
+	Highlight: lipgloss.Color("#E0AF68"),
+	Prompt:    lipgloss.Color("#BB9AF7"),
+	ToolName:  lipgloss.Color("#FF9E64"),
+
+	BgBase:     lipgloss.Color("#16161E"),
+	BgSurface:  lipgloss.Color("#1A1B26"),
+	BgElevated: lipgloss.Color("#24283B"),
+	Selection:  lipgloss.Color("#33467C"),
+
+	Text:          lipgloss.Color("#C0CAF5"),
+	TextSecondary: lipgloss.Color("#A9B1D6"),
+	TextMuted:     lipgloss.Color("#565F89"),
+	TextDim:       lipgloss.Color("#414868"),
+	OnColor:       lipgloss.Color("#1A1B26"),
+	OnAccent:      lipgloss.Color("#1A1B26"),
+
+	Success: lipgloss.Color("#9ECE6A"),
+	Warning: lipgloss.Color("#E0AF68"),
+	Error:   lipgloss.Color("#F7768E"),
+	Info:    lipgloss.Color("#7DCFFF"),
+
+	Border:  lipgloss.Color("#292E42"),
+	Divider: lipgloss.Color("#3B4261"),
+
+	ModeChat:  lipgloss.Color("#7AA2F7"),
+	ModeAgent: lipgloss.Color("#BB9AF7"),
+
+	Markdown: "tokyo-night",
+}
+
+// BuiltinThemes are the themes that ship with Arcane, in picker order
+var BuiltinThemes = []Theme{DarkTheme, LightTheme, DraculaTheme, NordTheme, GruvboxTheme, TokyoNightTheme}
+
+// CurrentTheme holds the active theme. Set it with Apply.
 var CurrentTheme = DarkTheme
 
-// Adaptive returns an AdaptiveColor that switches between light/dark
-type Adaptive = lipgloss.AdaptiveColor
-
-// Common adaptive colors for quick use
-var (
-	FgPrimary   = Adaptive{Light: string(LightTheme.Primary), Dark: string(DarkTheme.Primary)}
-	FgSecondary = Adaptive{Light: string(LightTheme.Secondary), Dark: string(DarkTheme.Secondary)}
-	FgMuted     = Adaptive{Light: string(LightTheme.TextMuted_), Dark: string(DarkTheme.TextMuted_)}
-	FgError     = Adaptive{Light: string(LightTheme.Error_), Dark: string(DarkTheme.Error_)}
-	FgSuccess   = Adaptive{Light: string(LightTheme.Success_), Dark: string(DarkTheme.Success_)}
-	FgWarning   = Adaptive{Light: string(LightTheme.Warning), Dark: string(DarkTheme.Warning)}
-	AccentColor = Adaptive{Light: string(LightTheme.Accent), Dark: string(DarkTheme.Accent)}
-
-	BgSurfaceColor = Adaptive{Light: string(LightTheme.BgSurface), Dark: string(DarkTheme.BgSurface)}
-	BgElevatedColor = Adaptive{Light: string(LightTheme.BgElevated), Dark: string(DarkTheme.BgElevated)}
-	BorderColor     = Adaptive{Light: string(LightTheme.Border), Dark: string(DarkTheme.Border)}
-)
+// AutoTheme picks the dark or light theme based on the terminal background
+func AutoTheme() Theme {
+	if lipgloss.HasDarkBackground() {
+		return DarkTheme
+	}
+	return LightTheme
+}
 
-// ProviderColorMap returns the color for each AI provider
-var ProviderColorMap = map[string]lipgloss.Color{
-	"Gemini":     lipgloss.Color("#A78BFA"),
-	"Xai":        lipgloss.Color(Pink),
-	"Deepseek":   lipgloss.Color(Cyan),
-	"MiniMax":    lipgloss.Color("#60A5FA"),
-	"Perplexity": lipgloss.Color(Amber),
-	"Z.ai":       lipgloss.Color(Success),
-	"OpenAI":     lipgloss.Color(Success),
+// Hex returns c in a form CSS accepts, converting ANSI 256 color numbers to
+// their usual "#RRGGBB"
+func Hex(c lipgloss.Color) string {
+	if n, err := strconv.Atoi(string(c)); err == nil && n >= 0 && n <= 255 {
+		return termenv.ANSI256Color(n).String()
+	}
+	return string(c)
 }
 
-// GetProviderColor returns the color for a provider
-func GetProviderColor(provider string) lipgloss.Color {
-	if c, ok := ProviderColorMap[provider]; ok {
+// ProviderColor returns the color for a provider header. Providers the theme
+// does not list are colored by role so every theme covers them.
+func (t Theme) ProviderColor(provider string) lipgloss.Color {
+	if c, ok := t.Providers[provider]; ok {
 		return c
 	}
-	return CurrentTheme.Primary
+	switch provider {
+	case "Gemini":
+		return t.Accent
+	case "Xai":
+		return t.ModeAgent
+	case "Deepseek":
+		return t.Secondary
+	case "MiniMax":
+		return t.Info
+	case "Perplexity":
+		return t.Highlight
+	case "Z.ai", "OpenAI":
+		return t.Success
+	}
+	return t.TextMuted
 }
 
-// InitTheme sets the current theme based on terminal background
-func InitTheme() {
-	if lipgloss.HasDarkBackground() {
-		CurrentTheme = DarkTheme
-	} else {
-		CurrentTheme = LightTheme
+// ThemeDir is where custom themes live, inside the arcane config dir
+func ThemeDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "themes"), nil
+}
+
+// LoadThemes returns the built-in themes followed by the custom ones in
+// ThemeDir, sorted by name. A custom theme with a built-in name replaces it.
+// Files that cannot be used are skipped and reported in the returned error.
+func LoadThemes() ([]Theme, error) {
+	themes := append([]Theme(nil), BuiltinThemes...)
+	dir, err := ThemeDir()
+	if err != nil {
+		return themes, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return themes, err
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".json"))
+		t, err := ParseTheme(name, data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("theme %s: %w", filepath.Base(path), err))
+			continue
+		}
+		t.Path = path
+		if i := themeIndex(themes, name); i >= 0 {
+			themes[i] = t
+		} else {
+			themes = append(themes, t)
+		}
+	}
+	return themes, errors.Join(errs...)
+}
+
+// FindTheme looks up a theme by case-insensitive name
+func FindTheme(themes []Theme, name string) (Theme, bool) {
+	if i := themeIndex(themes, name); i >= 0 {
+		return themes[i], true
+	}
+	return Theme{}, false
+}
+
+func themeIndex(themes []Theme, name string) int {
+	for i, t := range themes {
+		if strings.EqualFold(t.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+var colorPattern = regexp.MustCompile(`^(#[0-9A-Fa-f]{3}|#[0-9A-Fa-f]{6}|[0-9]{1,3})$`)
+
+// ParseTheme reads a theme file. "base" names the built-in theme it starts
+// from (dark unless set), so a file only needs the colors it changes.
+func ParseTheme(name string, data []byte) (Theme, error) {
+	var head struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return Theme{}, err
+	}
+	if head.Base == "" {
+		head.Base = DarkTheme.Name
+	}
+	t, ok := FindTheme(BuiltinThemes, head.Base)
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown base theme %q", head.Base)
+	}
+	t.Providers = maps.Clone(t.Providers)
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Theme{}, err
+	}
+	t.Name = name
+
+	v := reflect.ValueOf(t)
+	for i := 0; i < v.NumField(); i++ {
+		c, ok := v.Field(i).Interface().(lipgloss.Color)
+		if ok && !colorPattern.MatchString(string(c)) {
+			tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return Theme{}, fmt.Errorf("%s: invalid color %q", tag, c)
+		}
+	}
+	for provider, c := range t.Providers {
+		if !colorPattern.MatchString(string(c)) {
+			return Theme{}, fmt.Errorf("providers.%s: invalid color %q", provider, c)
+		}
 	}
+	return t, nil
 }
@@ -0,0 +1,223 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"arcane/internal/config"
+)
+
+// ProjectDir is where a project keeps its templates, relative to its root
+const ProjectDir = ".arcane/templates"
+
+// Template is a saved prompt stored as a Markdown file
+type Template struct {
+	Name        string // File name without .md, used as the slash command
+	Description string
+	Body        string
+	Path        string
+	Project     bool // From the project rather than the config dir
+}
+
+var (
+	namePattern = regexp.MustCompile(`^[A-Za-z][\w-]*$`)
+	varPattern  = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w-]*)\s*\}\}`)
+)
+
+// Builtins are filled in automatically rather than from positional arguments
+var Builtins = []string{"args", "cwd", "date", "project"}
+
+// Dirs returns the user template dir followed by the project one
+func Dirs(projectRoot string) []string {
+	var dirs []string
+	if dir, err := config.Dir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "templates"))
+	}
+	if projectRoot != "" {
+		dirs = append(dirs, filepath.Join(projectRoot, ProjectDir))
+	}
+	return dirs
+}
+
+// Load reads the templates for a project, sorted by name. Project templates
+// replace user templates with the same name. Templates that cannot be read
+// are skipped and reported in the returned error.
+func Load(projectRoot string) ([]Template, error) {
+	byName := map[string]Template{}
+	var errs []error
+	dirs := Dirs(projectRoot)
+	for i, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, path := range paths {
+			t, err := read(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			t.Project = projectRoot != "" && i == len(dirs)-1
+			byName[strings.ToLower(t.Name)] = t
+		}
+	}
+
+	out := make([]Template, 0, len(byName))
+	for _, t := range byName {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, errors.Join(errs...)
+}
+
+func read(path string) (Template, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if !namePattern.MatchString(name) {
+		return Template{}, fmt.Errorf("template %s: name must start with a letter and contain only letters, digits, - and _", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Template{}, err
+	}
+	t := Template{Name: name, Path: path}
+	t.Description, t.Body = parse(string(data))
+	if t.Body == "" {
+		return Template{}, fmt.Errorf("template %s is empty", path)
+	}
+	return t, nil
+}
+
+// parse splits off an optional front matter block holding "description:".
+// Without one the first line of the body describes the template, and is
+// dropped from it when it is a Markdown heading.
+func parse(text string) (description, body string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if header, after, found := strings.Cut(rest, "\n---"); found {
+			for _, line := range strings.Split(header, "\n") {
+				if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "description" {
+					description = strings.Trim(strings.TrimSpace(value), `"'`)
+				}
+			}
+			text = strings.TrimPrefix(after, "\n")
+		}
+	}
+	body = strings.TrimSpace(text)
+	if description == "" {
+		first, rest, _ := strings.Cut(body, "\n")
+		description = strings.TrimSpace(strings.TrimLeft(first, "# "))
+		// A leading heading names the template rather than being part of it
+		if strings.HasPrefix(first, "#") {
+			body = strings.TrimSpace(rest)
+		}
+	}
+	return description, body
+}
+
+// Vars returns the template's variables in order of first use, leaving out
+// the builtins
+func (t Template) Vars() []string {
+	var vars []string
+	for _, match := range varPattern.FindAllStringSubmatch(t.Body, -1) {
+		name := match[1]
+		if !slices.Contains(Builtins, name) && !slices.Contains(vars, name) {
+			vars = append(vars, name)
+		}
+	}
+	return vars
+}
+
+// Expand fills in the template. Arguments of the form name=value set that
+// variable; the others fill the remaining variables in order, and {{args}}
+// receives whatever is left over. Variables without a value are left in place
+// to be edited before sending.
+func (t Template) Expand(rawArgs string, builtins map[string]string) (string, error) {
+	args, err := SplitArgs(rawArgs)
+	if err != nil {
+		return "", err
+	}
+	vars := t.Vars()
+	values := map[string]string{}
+	var positional []string
+	for _, arg := range args {
+		if name, value, ok := strings.Cut(arg, "="); ok && slices.Contains(vars, name) {
+			values[name] = value
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	usesArgs := false
+	for _, match := range varPattern.FindAllStringSubmatch(t.Body, -1) {
+		usesArgs = usesArgs || match[1] == "args"
+	}
+	for _, name := range vars {
+		if len(positional) == 0 {
+			break
+		}
+		if _, ok := values[name]; !ok {
+			values[name] = positional[0]
+			positional = positional[1:]
+		}
+	}
+	if len(positional) > 0 && !usesArgs {
+		return "", fmt.Errorf("too many arguments (variables: %s)", strings.Join(vars, ", "))
+	}
+
+	for k, v := range builtins {
+		values[k] = v
+	}
+	values["args"] = strings.Join(positional, " ")
+
+	return varPattern.ReplaceAllStringFunc(t.Body, func(m string) string {
+		name := varPattern.FindStringSubmatch(m)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return m
+	}), nil
+}
+
+// SplitArgs splits on whitespace. An argument starting with a double or single
+// quote runs to the matching quote.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case (r == '"' || r == '\'') && !inArg:
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"slices"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// Names lists the available tool names in definition order
+func Names() []string {
+	names := make([]string, 0, len(Definitions))
+	for _, def := range Definitions {
+		if def.OfFunction != nil {
+			names = append(names, def.OfFunction.Function.Name)
+		}
+	}
+	return names
+}
+
+// IsAllowed reports whether a tool may run; an empty allow list allows every tool
+func IsAllowed(name string, allowed []string) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, name)
+}
+
+// Allowed returns the definitions for the allowed tools
+func Allowed(allowed []string) []openai.ChatCompletionToolUnionParam {
+	if len(allowed) == 0 {
+		return Definitions
+	}
+	defs := make([]openai.ChatCompletionToolUnionParam, 0, len(allowed))
+	for _, def := range Definitions {
+		if def.OfFunction != nil && IsAllowed(def.OfFunction.Function.Name, allowed) {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
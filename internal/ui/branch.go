@@ -0,0 +1,252 @@
+package ui
+
+import (
+	"arcane/internal/db"
+	"arcane/internal/models"
+	"fmt"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/openai/openai-go/v3"
+)
+
+// showBranch replaces the transcript and history with a branch loaded from the DB
+func (m *Model) showBranch(msgs []models.DBMessage) {
+	m.Path = msgs
+	m.LeafMessageID = 0
+	if n := len(msgs); n > 0 {
+		m.LeafMessageID = msgs[n-1].ID
+	}
+	m.Messages = []ChatMessage{}
+	m.FocusedMessage = -1
+	m.History = []openai.ChatCompletionMessageParamUnion{}
+
+	for _, msg := range msgs {
+		switch msg.Role {
+		case models.RoleUser:
+			m.History = append(m.History, openai.UserMessage(msg.Content))
+		case models.RoleAssistant:
+			m.History = append(m.History, openai.AssistantMessage(msg.Content))
+		case models.RoleEvent:
+		default:
+			continue
+		}
+		m.Messages = append(m.Messages, chatMessageFromDB(msg))
+	}
+
+	if m.inFront() {
+		m.UpdateViewport()
+	}
+}
+
+// refreshPath reloads branch metadata for the active leaf without re-rendering
+func (m *Model) refreshPath() {
+	if m.DB == nil || m.CurrentChatID == 0 {
+		return
+	}
+	if path, err := db.GetBranchMessages(m.DB, m.CurrentChatID, m.LeafMessageID); err == nil {
+		m.Path = path
+	}
+}
+
+func lastUserIndex(path []models.DBMessage) int {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Role == models.RoleUser {
+			return i
+		}
+	}
+	return -1
+}
+
+func pathIndex(path []models.DBMessage, id int64) int {
+	for i, msg := range path {
+		if msg.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Regenerate asks for a new answer to the last prompt, optionally on another
+// model. The new answer is stored as a sibling of the old one.
+func (m *Model) Regenerate(modelQuery string) (tea.Cmd, error) {
+	if m.Loading {
+		return nil, fmt.Errorf("wait for the current response to finish")
+	}
+	idx := lastUserIndex(m.Path)
+	if idx < 0 {
+		return nil, fmt.Errorf("nothing to regenerate")
+	}
+	if modelQuery != "" {
+		mdl, i, ok := FindModelByQuery(modelQuery)
+		if !ok {
+			return nil, fmt.Errorf("unknown model %q", modelQuery)
+		}
+		m.CurrentModel = mdl
+		m.SelectedModelIndex = i
+	}
+
+	prompt := m.Path[idx]
+	m.cancelEdit()
+	m.showBranch(m.Path[:idx+1])
+	m.reloadInstructions()
+	// SendMessage appends the prompt itself
+	m.History = m.History[:len(m.History)-1]
+	_, m.AttachedFiles = ExtractFileMentions(prompt.Content)
+	return m.startRequest(prompt.Content), nil
+}
+
+// EditPreviousMessage loads the previous user message into the input. Sending
+// it forks the chat at that message instead of overwriting it.
+func (m *Model) EditPreviousMessage() {
+	if m.Loading {
+		return
+	}
+	start := len(m.Path)
+	if m.EditingMessageID != 0 {
+		if i := pathIndex(m.Path, m.EditingMessageID); i >= 0 {
+			start = i
+		}
+	}
+	idx := lastUserIndex(m.Path[:start])
+	if idx < 0 {
+		// Wrap around to the most recent prompt
+		idx = lastUserIndex(m.Path)
+	}
+	if idx < 0 {
+		return
+	}
+
+	m.EditingMessageID = m.Path[idx].ID
+	m.TextInput.SetValue(m.Path[idx].Content)
+	m.FileSuggestOpen = false
+	m.updateInputLayout()
+}
+
+func (m *Model) cancelEdit() {
+	if m.EditingMessageID == 0 {
+		return
+	}
+	m.EditingMessageID = 0
+	m.TextInput.Reset()
+	m.updateInputLayout()
+}
+
+// forkAtEdit rewinds the transcript to just before the message being edited,
+// so the next persisted message becomes its sibling.
+func (m *Model) forkAtEdit() {
+	idx := pathIndex(m.Path, m.EditingMessageID)
+	m.EditingMessageID = 0
+	if idx < 0 {
+		return
+	}
+	m.showBranch(m.Path[:idx])
+}
+
+// Undo rewinds the transcript to before the last prompt and puts the prompt
+// back in the input. Nothing is deleted: sending again stores the new
+// exchange as a sibling branch, and reopening the chat shows the old one.
+func (m *Model) Undo() error {
+	if m.Loading {
+		return fmt.Errorf("wait for the current response to finish")
+	}
+	idx := lastUserIndex(m.Path)
+	if idx < 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+	prompt := m.Path[idx].Content
+	m.EditingMessageID = 0
+	m.showBranch(m.Path[:idx])
+	m.TextInput.SetValue(prompt)
+	m.updateInputLayout()
+	return nil
+}
+
+// LastAnswer returns the Markdown of the newest answer in the transcript
+func (m *Model) LastAnswer() (string, bool) {
+	for i := len(m.Messages) - 1; i >= 0; i-- {
+		if m.Messages[i].Role == models.RoleAssistant && m.Messages[i].Content != "" {
+			return m.Messages[i].Content, true
+		}
+	}
+	return "", false
+}
+
+// SwitchBranch moves the deepest fork on the active branch to its previous
+// (dir < 0) or next (dir > 0) sibling and shows that sibling's newest leaf.
+func (m *Model) SwitchBranch(dir int) error {
+	if m.Loading || m.DB == nil || m.CurrentChatID == 0 {
+		return nil
+	}
+	for i := len(m.Path) - 1; i >= 0; i-- {
+		msg := m.Path[i]
+		if msg.SiblingCount < 2 {
+			continue
+		}
+		ids, err := db.GetSiblingIDs(m.DB, m.CurrentChatID, msg.ParentID)
+		if err != nil {
+			return err
+		}
+		if len(ids) < 2 {
+			return nil
+		}
+		next := ids[(msg.SiblingIndex+dir+len(ids))%len(ids)]
+		leaf, err := db.LatestLeaf(m.DB, m.CurrentChatID, next)
+		if err != nil {
+			return err
+		}
+		if err := db.SetChatLeaf(m.DB, m.CurrentChatID, leaf); err != nil {
+			return err
+		}
+		msgs, err := db.GetBranchMessages(m.DB, m.CurrentChatID, leaf)
+		if err != nil {
+			return err
+		}
+		m.cancelEdit()
+		m.showBranch(msgs)
+		return nil
+	}
+	return nil
+}
+
+// ForkChat copies a chat's active branch (up to uptoMessageID when non-zero)
+// into a new chat and opens it. The original chat is not modified.
+func (m *Model) ForkChat(chatID, uptoMessageID int64) (int64, error) {
+	if m.DBErr != nil {
+		return 0, m.DBErr
+	}
+	if m.DB == nil {
+		return 0, fmt.Errorf("history database not initialized")
+	}
+	if m.Loading {
+		return 0, fmt.Errorf("wait for the current response to finish")
+	}
+
+	newID, err := db.ForkChat(m.DB, chatID, uptoMessageID, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	chat, err := db.GetChat(m.DB, newID)
+	if err != nil {
+		return 0, err
+	}
+	m.cancelEdit()
+	return newID, m.LoadChatFromDB(chat)
+}
+
+// forkCommand handles "/fork [n]", where n is a 1-based message number
+func (m *Model) forkCommand(arg string) (int64, error) {
+	if m.CurrentChatID == 0 {
+		return 0, fmt.Errorf("nothing to fork yet")
+	}
+	var upto int64
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(m.Path) {
+			return 0, fmt.Errorf("usage: /fork [1-%d]", len(m.Path))
+		}
+		upto = m.Path[n-1].ID
+	}
+	return m.ForkChat(m.CurrentChatID, upto)
+}
@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// CopyToClipboard puts text on the system clipboard. Over SSH, or when
+// there is no system clipboard, it returns a command sending an OSC 52
+// escape instead, which reaches the local terminal.
+func (m *Model) CopyToClipboard(text string) (tea.Cmd, error) {
+	if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+		err := clipboard.WriteAll(text)
+		if err == nil {
+			return nil, nil
+		}
+		if m.Output == nil {
+			return nil, err
+		}
+	}
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	osc := m.writeTerminal(seq.String())
+	if osc == nil {
+		return nil, errors.New("no clipboard available")
+	}
+	return osc, nil
+}
@@ -0,0 +1,573 @@
+package ui
+
+import (
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Command is a slash command typed into the input box
+type Command struct {
+	Name        string // Without the leading slash
+	Aliases     []string
+	Args        string // Argument synopsis, e.g. "[md|json|html] [path]"
+	Description string
+	Keys        []string // KeyMap actions that do the same, shown in the palette
+	Template    bool     // Expands a prompt template rather than running an action
+
+	// Run executes the command. The input is cleared before Run is called and
+	// restored if it returns an error.
+	Run func(m *Model, args string) (tea.Cmd, error)
+}
+
+// maxCommandSuggestions caps the autocomplete popup height
+const maxCommandSuggestions = 8
+
+// commandPattern matches "/name" or "/name args"; other input starting with a
+// slash, such as a path, is sent as a message
+var commandPattern = regexp.MustCompile(`^/([A-Za-z][\w-]*)(?:\s+([\s\S]*))?$`)
+
+// builtinCommands is assigned in init because /help refers back to the list
+var builtinCommands []Command
+
+func init() {
+	builtinCommands = []Command{
+		{
+			Name:        "clear",
+			Aliases:     []string{"reset", "new"},
+			Description: "Start a new chat",
+			Keys:        []string{"new_chat"},
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				m.ResetSession()
+				return nil, nil
+			},
+		},
+		{
+			Name:        "model",
+			Args:        "[name|id]",
+			Description: "Switch model, or pick one from the list",
+			Keys:        []string{"select_model"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				if args == "" {
+					m.OpenModelSelector()
+					return nil, nil
+				}
+				mdl, idx, ok := FindModelByQuery(args)
+				if !ok {
+					return nil, fmt.Errorf("unknown model %q", args)
+				}
+				m.CurrentModel = mdl
+				m.SelectedModelIndex = idx
+				m.AddNote(styles.InfoStyle("Model: " + mdl.Name))
+				return nil, nil
+			},
+		},
+		{
+			Name:        "mode",
+			Args:        "[chat|agent]",
+			Description: "Switch between Chat and Agent mode",
+			Keys:        []string{"toggle_mode"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				switch strings.ToLower(args) {
+				case "":
+					if m.AppMode == models.ModeAgent {
+						return nil, m.SetMode(models.ModeChat)
+					}
+					return nil, m.SetMode(models.ModeAgent)
+				case "chat":
+					return nil, m.SetMode(models.ModeChat)
+				case "agent":
+					return nil, m.SetMode(models.ModeAgent)
+				}
+				return nil, fmt.Errorf("usage: /mode [chat|agent]")
+			},
+		},
+		{
+			Name:        "persona",
+			Args:        "[name|default]",
+			Description: "Switch persona, or pick one from the list",
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				return nil, m.personaCommand(args)
+			},
+		},
+		{
+			Name:        "templates",
+			Aliases:     []string{"tmpl"},
+			Description: "Pick a prompt template to insert",
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				m.OpenTemplateSelector()
+				return nil, nil
+			},
+		},
+		{
+			Name:        "theme",
+			Args:        "[name|auto]",
+			Description: "Switch color theme, or preview them in a picker",
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				name := strings.ToLower(strings.TrimSpace(args))
+				if name == "" {
+					m.OpenThemeSelector()
+					return nil, nil
+				}
+				return nil, m.SetTheme(name)
+			},
+		},
+		{
+			Name:        "effort",
+			Args:        "[minimal|low|medium|high|default]",
+			Description: "Set how hard the current model thinks, or show it",
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				effort := strings.ToLower(strings.TrimSpace(args))
+				if effort != "" {
+					if err := m.SetReasoningEffort(effort); err != nil {
+						return nil, err
+					}
+				}
+				m.AddNote(styles.InfoStyle(m.reasoningEffortText()))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+				return nil, nil
+			},
+		},
+		{
+			Name:        "history",
+			Description: "Browse stored chats",
+			Keys:        []string{"history"},
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				m.OpenHistory()
+				return nil, nil
+			},
+		},
+		{
+			Name:        "tab",
+			Args:        "[new|close|next|prev|n]",
+			Description: "Open, close or switch tabs",
+			Keys:        []string{"new_tab"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				switch arg := strings.TrimSpace(args); arg {
+				case "", "new":
+					m.NewTab()
+				case "close":
+					return nil, m.CloseTab()
+				case "next":
+					m.SwitchTab(1)
+				case "prev":
+					m.SwitchTab(-1)
+				default:
+					n, err := strconv.Atoi(arg)
+					if err != nil {
+						return nil, fmt.Errorf("expected new, close, next, prev or a tab number")
+					}
+					return nil, m.GotoTab(n)
+				}
+				return nil, nil
+			},
+		},
+		{
+			Name:        "regen",
+			Args:        "[model]",
+			Description: "Regenerate the last answer",
+			Keys:        []string{"regenerate"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				return m.Regenerate(args)
+			},
+		},
+		{
+			Name:        "edit",
+			Args:        "[text]",
+			Description: "Compose the input in $VISUAL or $EDITOR",
+			Keys:        []string{"editor"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				// From the palette the input still holds the draft
+				if args == "" {
+					args = m.TextInput.Value()
+				}
+				return m.OpenEditor(args)
+			},
+		},
+		{
+			Name:        "amend",
+			Description: "Edit a previous message and resend it as a new branch",
+			Keys:        []string{"edit_previous"},
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				if m.Loading {
+					return nil, fmt.Errorf("wait for the current response to finish")
+				}
+				m.EditPreviousMessage()
+				return nil, nil
+			},
+		},
+		{
+			Name:        "undo",
+			Description: "Take back the last exchange and put its prompt in the input",
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				return nil, m.Undo()
+			},
+		},
+		{
+			Name:        "branch",
+			Args:        "[prev|next]",
+			Description: "Switch to a sibling branch",
+			Keys:        []string{"prev_branch", "next_branch"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				switch strings.ToLower(args) {
+				case "prev":
+					return nil, m.SwitchBranch(-1)
+				case "", "next":
+					return nil, m.SwitchBranch(1)
+				}
+				return nil, fmt.Errorf("usage: /branch [prev|next]")
+			},
+		},
+		{
+			Name:        "copy",
+			Description: "Copy the last answer to the clipboard as Markdown",
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				answer, ok := m.LastAnswer()
+				if !ok {
+					return nil, fmt.Errorf("no answer to copy yet")
+				}
+				cmd, err := m.CopyToClipboard(answer)
+				if err != nil {
+					return nil, err
+				}
+				m.AddNote(styles.InfoStyle(fmt.Sprintf("Copied %d characters", len([]rune(answer)))))
+				return cmd, nil
+			},
+		},
+		{
+			Name:        "find",
+			Aliases:     []string{"search"},
+			Args:        "[text]",
+			Description: "Search this conversation",
+			Keys:        []string{"find"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				if len(m.Messages) == 0 {
+					return nil, fmt.Errorf("nothing to search yet")
+				}
+				m.OpenChatSearch(strings.TrimSpace(args))
+				if m.ChatSearchQuery != "" {
+					m.ChatSearchEditing = false
+				}
+				return nil, nil
+			},
+		},
+		{
+			Name:        "select",
+			Description: "Pick a message or code block to copy",
+			Keys:        []string{"copy_mode"},
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				return nil, m.OpenCopySelector()
+			},
+		},
+		{
+			Name:        "steer",
+			Args:        "<text>",
+			Description: "Add instructions to the running agent turn",
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				text := strings.TrimSpace(args)
+				if text == "" {
+					return nil, fmt.Errorf("usage: /steer <text>")
+				}
+				return nil, m.Steer(text)
+			},
+		},
+		{
+			Name:        "queue",
+			Args:        "[edit|clear]",
+			Description: "Edit or drop messages queued during generation",
+			Keys:        []string{"edit_queued"},
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				switch strings.TrimSpace(args) {
+				case "", "edit":
+					return nil, m.EditQueued()
+				case "clear":
+					if len(m.Queued) == 0 {
+						return nil, fmt.Errorf("no queued messages")
+					}
+					m.Queued = nil
+					return nil, nil
+				default:
+					return nil, fmt.Errorf("usage: /queue [edit|clear]")
+				}
+			},
+		},
+		{
+			Name:        "fork",
+			Args:        "[n]",
+			Description: "Copy this chat, up to message n, into a new chat",
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				srcID := m.CurrentChatID
+				if _, err := m.forkCommand(args); err != nil {
+					return nil, err
+				}
+				m.AddNote(styles.InfoStyle(fmt.Sprintf("Forked chat #%d into #%d", srcID, m.CurrentChatID)))
+				return nil, nil
+			},
+		},
+		{
+			Name:        "export",
+			Args:        "[md|json|html] [path]",
+			Description: "Write this chat to a file",
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				path, err := m.ExportChat(args)
+				if err != nil {
+					return nil, err
+				}
+				m.AddNote(styles.InfoStyle("Exported to " + path))
+				return nil, nil
+			},
+		},
+		{
+			Name:        "shortcuts",
+			Description: "Show keyboard shortcuts",
+			Keys:        []string{"shortcuts"},
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				m.ShortcutsOpen = true
+				m.ModelSelectorOpen = false
+				m.HistoryOpen = false
+				return nil, nil
+			},
+		},
+		{
+			Name:        "help",
+			Description: "List slash commands",
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				m.AddNote(m.commandHelp())
+				return nil, nil
+			},
+		},
+		{
+			Name:        "quit",
+			Aliases:     []string{"exit"},
+			Description: "Quit Arcane",
+			Keys:        []string{"quit"},
+			Run: func(m *Model, _ string) (tea.Cmd, error) {
+				return tea.Quit, nil
+			},
+		},
+	}
+}
+
+// Commands returns every slash command available in the current session:
+// the built-ins followed by prompt templates
+func (m *Model) Commands() []Command {
+	return append(slices.Clip(builtinCommands), m.templateCommands()...)
+}
+
+// FindCommand looks a command up by name or alias, ignoring case
+func (m *Model) FindCommand(name string) (Command, bool) {
+	return findCommand(m.Commands(), name)
+}
+
+func findCommand(cmds []Command, name string) (Command, bool) {
+	for _, c := range cmds {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+		for _, a := range c.Aliases {
+			if strings.EqualFold(a, name) {
+				return c, true
+			}
+		}
+	}
+	return Command{}, false
+}
+
+// ParseCommand splits "/name args" into its parts. ok is false when the input
+// is not shaped like a command.
+func ParseCommand(input string) (name, args string, ok bool) {
+	match := commandPattern.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], strings.TrimSpace(match[2]), true
+}
+
+// RunCommand executes a slash command typed into the input. Errors, including
+// unknown commands, are shown in the transcript and leave the input in place.
+func (m *Model) RunCommand(input string) tea.Cmd {
+	name, args, _ := ParseCommand(input)
+	m.LoadTemplates()
+	c, found := m.FindCommand(name)
+	if !found {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Unknown command /%s (type /help for a list)", name)))
+		m.UpdateViewport()
+		m.Viewport.GotoBottom()
+		return nil
+	}
+
+	m.TextInput.Reset()
+	m.CommandSuggestOpen = false
+	cmd, ok := m.execCommand(c, args)
+	if !ok {
+		m.TextInput.SetValue(input)
+	}
+	m.updateInputLayout()
+	return cmd
+}
+
+// execCommand runs c and shows any error in the transcript
+func (m *Model) execCommand(c Command, args string) (tea.Cmd, bool) {
+	cmd, err := c.Run(m, args)
+	if err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("/%s: %v", c.Name, err)))
+	}
+	if len(m.Messages) > 0 {
+		m.UpdateViewport()
+		m.Viewport.GotoBottom()
+	}
+	return cmd, err == nil
+}
+
+// commandUsage renders "/name args" for suggestions and help
+func commandUsage(c Command) string {
+	if c.Args == "" {
+		return "/" + c.Name
+	}
+	return "/" + c.Name + " " + c.Args
+}
+
+func (m *Model) commandHelp() string {
+	cmds := m.Commands()
+	width := 0
+	for _, c := range cmds {
+		width = max(width, len(commandUsage(c)))
+	}
+	lines := []string{"Commands:"}
+	for i, c := range cmds {
+		if c.Template && (i == 0 || !cmds[i-1].Template) {
+			lines = append(lines, "Templates:")
+		}
+		desc := c.Description
+		if len(c.Aliases) > 0 {
+			desc += " (also /" + strings.Join(c.Aliases, ", /") + ")"
+		}
+		lines = append(lines, fmt.Sprintf("  %-*s  %s", width, commandUsage(c), desc))
+	}
+	tip := "Start a message with // to send a literal leading slash."
+	if keys := m.KeyHelp("shortcuts"); keys != "" {
+		tip += " " + keys + " lists keyboard shortcuts."
+	}
+	lines = append(lines, tip)
+	return styles.InfoStyle(strings.Join(lines, "\n"))
+}
+
+// updateCommandSuggestions opens the autocomplete popup while the first word
+// of the input is a partial command name
+func (m *Model) updateCommandSuggestions() {
+	val := m.TextInput.Value()
+	m.CommandSuggestOpen = false
+	if !strings.HasPrefix(val, "/") || strings.ContainsAny(val, " \t\n") {
+		return
+	}
+	if val == "/" {
+		m.LoadTemplates()
+	}
+	prefix := strings.ToLower(val[1:])
+
+	var matches []Command
+	for _, c := range m.Commands() {
+		if strings.HasPrefix(strings.ToLower(c.Name), prefix) {
+			matches = append(matches, c)
+			continue
+		}
+		for _, a := range c.Aliases {
+			if strings.HasPrefix(a, prefix) {
+				matches = append(matches, c)
+				break
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+	// Keep the highlighted command when it still matches
+	idx := 0
+	if m.CommandSuggestIdx < len(m.CommandSuggestions) {
+		selected := m.CommandSuggestions[m.CommandSuggestIdx].Name
+		for i, c := range matches {
+			if c.Name == selected {
+				idx = i
+			}
+		}
+	}
+	m.CommandSuggestions = matches
+	m.CommandSuggestIdx = idx
+	m.CommandSuggestOpen = true
+}
+
+// completeCommand replaces the input with the highlighted command. It returns
+// true when the command takes no arguments and can run straight away.
+func (m *Model) completeCommand() bool {
+	c := m.CommandSuggestions[m.CommandSuggestIdx]
+	m.CommandSuggestOpen = false
+	if c.Args == "" {
+		m.TextInput.SetValue("/" + c.Name)
+		m.updateInputLayout()
+		return true
+	}
+	m.TextInput.SetValue("/" + c.Name + " ")
+	m.updateInputLayout()
+	return false
+}
+
+func (m *Model) RenderCommandSuggestions() string {
+	if !m.CommandSuggestOpen || len(m.CommandSuggestions) == 0 {
+		return ""
+	}
+
+	suggestionStyle := lipgloss.NewStyle().
+		Foreground(styles.CurrentTheme.TextSecondary).
+		Padding(0, 1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(styles.CurrentTheme.OnAccent).
+		Background(styles.CurrentTheme.Accent).
+		Padding(0, 1)
+
+	descStyle := lipgloss.NewStyle().Foreground(styles.CurrentTheme.TextMuted)
+
+	var lines []string
+	header := lipgloss.NewStyle().
+		Foreground(styles.CurrentTheme.TextMuted).
+		Italic(true).
+		Render("  commands (↑↓ select, Tab complete, Enter run)")
+	lines = append(lines, header)
+
+	// Scroll the window so the selection stays visible
+	start := 0
+	if m.CommandSuggestIdx >= maxCommandSuggestions {
+		start = m.CommandSuggestIdx - maxCommandSuggestions + 1
+	}
+	end := min(start+maxCommandSuggestions, len(m.CommandSuggestions))
+
+	width := 0
+	for _, c := range m.CommandSuggestions {
+		width = max(width, len(commandUsage(c)))
+	}
+	for i := start; i < end; i++ {
+		c := m.CommandSuggestions[i]
+		usage := fmt.Sprintf("%-*s", width, commandUsage(c))
+		if i == m.CommandSuggestIdx {
+			lines = append(lines, selectedStyle.Render("▸ "+usage+"  "+c.Description))
+		} else {
+			lines = append(lines, suggestionStyle.Render("  "+usage+"  "+descStyle.Render(c.Description)))
+		}
+	}
+
+	popupStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(styles.CurrentTheme.Primary).
+		Background(styles.CurrentTheme.BgElevated).
+		Padding(0, 1)
+
+	return popupStyle.Render(strings.Join(lines, "\n"))
+}
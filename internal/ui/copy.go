@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// markdownParser finds fenced code blocks in messages
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+
+// CodeBlock is a fenced code block inside a message
+type CodeBlock struct {
+	Language string
+	Code     string
+}
+
+// Lines counts the lines of code in the block
+func (b CodeBlock) Lines() int {
+	return strings.Count(strings.TrimSuffix(b.Code, "\n"), "\n") + 1
+}
+
+// CodeBlocks returns the fenced code blocks of a Markdown document in order
+func CodeBlocks(markdown string) []CodeBlock {
+	source := []byte(markdown)
+	doc := markdownParser.Parse(text.NewReader(source))
+	var blocks []CodeBlock
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		fenced, ok := n.(*ast.FencedCodeBlock)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		var code strings.Builder
+		lines := fenced.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			code.Write(seg.Value(source))
+		}
+		blocks = append(blocks, CodeBlock{Language: string(fenced.Language(source)), Code: code.String()})
+		return ast.WalkSkipChildren, nil
+	})
+	return blocks
+}
+
+// copyableMessages returns the prompts and answers shown in the transcript,
+// with their indexes in Messages
+func (m *Model) copyableMessages() ([]ChatMessage, []int) {
+	var msgs []ChatMessage
+	var idx []int
+	for i, msg := range m.Messages {
+		if msg.navigable() && msg.Content != "" {
+			msgs = append(msgs, msg)
+			idx = append(idx, i)
+		}
+	}
+	return msgs, idx
+}
+
+// OpenCopySelector starts message selection on the message jumped to, or
+// the newest one
+func (m *Model) OpenCopySelector() error {
+	msgs, idx := m.copyableMessages()
+	if len(msgs) == 0 {
+		return fmt.Errorf("no messages to copy yet")
+	}
+	m.CopySelectorOpen = true
+	m.CopySelectedIdx = len(msgs) - 1
+	for i, mi := range idx {
+		if mi == m.FocusedMessage {
+			m.CopySelectedIdx = i
+		}
+	}
+	m.ModelSelectorOpen = false
+	m.HistoryOpen = false
+	m.ShortcutsOpen = false
+	return nil
+}
+
+// copySelected copies the selected message, or its nth code block when n > 0
+func (m *Model) copySelected(n int) (tea.Cmd, error) {
+	msgs, _ := m.copyableMessages()
+	if m.CopySelectedIdx >= len(msgs) {
+		return nil, fmt.Errorf("no message selected")
+	}
+	content := msgs[m.CopySelectedIdx].copyText()
+	note := fmt.Sprintf("Copied message %d (%d characters)", m.CopySelectedIdx+1, len([]rune(content)))
+	if n > 0 {
+		blocks := CodeBlocks(content)
+		if n > len(blocks) {
+			return nil, fmt.Errorf("message %d has %d code blocks", m.CopySelectedIdx+1, len(blocks))
+		}
+		b := blocks[n-1]
+		content, note = b.Code, fmt.Sprintf("Copied code block %d of message %d (%s)", n, m.CopySelectedIdx+1, codeBlockLabel(b))
+	}
+	cmd, err := m.CopyToClipboard(content)
+	if err != nil {
+		return nil, err
+	}
+	m.AddNote(styles.InfoStyle(note))
+	return cmd, nil
+}
+
+// copyText is the message as written: the prompt as typed rather than the
+// text shown for it
+func (msg ChatMessage) copyText() string {
+	if msg.Prompt != "" {
+		return msg.Prompt
+	}
+	return msg.Content
+}
+
+func codeBlockLabel(b CodeBlock) string {
+	lang := b.Language
+	if lang == "" {
+		lang = "text"
+	}
+	lines := "lines"
+	if b.Lines() == 1 {
+		lines = "line"
+	}
+	return fmt.Sprintf("%s, %d %s", lang, b.Lines(), lines)
+}
+
+func (m *Model) RenderCopySelector() string {
+	title := styles.ModalTitleStyle.Render("Copy from Chat")
+	msgs, _ := m.copyableMessages()
+
+	// Show a window of messages around the selection
+	const rows = 8
+	start := max(0, min(m.CopySelectedIdx-rows/2, len(msgs)-rows))
+	end := min(start+rows, len(msgs))
+
+	var items []string
+	for i := start; i < end; i++ {
+		msg := msgs[i]
+		who := "YOU"
+		if msg.Role == models.RoleAssistant {
+			who = "ARCANE"
+		}
+		preview := strings.Join(strings.Fields(msg.Content), " ")
+		line := fmt.Sprintf("%-6s %s  %s", who, msg.CreatedAt.Format("15:04"), preview)
+		style := styles.ModalItemStyle.Copy().Width(styles.ContentWidth)
+		if i == m.CopySelectedIdx {
+			style = styles.ModalSelectedStyle.Copy().Width(styles.ContentWidth)
+		}
+		items = append(items, style.Render(TruncateRunes(line, styles.ContentWidth-4)))
+	}
+
+	var blocks []string
+	if m.CopySelectedIdx < len(msgs) {
+		for i, b := range CodeBlocks(msgs[m.CopySelectedIdx].copyText()) {
+			if i == 9 {
+				break
+			}
+			first, _, _ := strings.Cut(strings.TrimSpace(b.Code), "\n")
+			blocks = append(blocks, lipgloss.NewStyle().Foreground(styles.CurrentTheme.TextSecondary).
+				Render(TruncateRunes(fmt.Sprintf("  [%d] %s · %s", i+1, codeBlockLabel(b), first), styles.ContentWidth)))
+		}
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, lipgloss.NewStyle().Foreground(styles.HintColor).Render("  No code blocks in this message"))
+	}
+
+	hint := lipgloss.NewStyle().
+		Foreground(styles.HintColor).
+		Width(styles.ContentWidth).
+		PaddingTop(1).
+		Render(fmt.Sprintf("↑/↓: message %d/%d • Enter: copy Markdown • 1-9: copy code block • Esc: close", m.CopySelectedIdx+1, len(msgs)))
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		lipgloss.JoinVertical(lipgloss.Left, items...),
+		"",
+		lipgloss.NewStyle().Foreground(styles.CurrentTheme.Secondary).Render("Code blocks"),
+		lipgloss.JoinVertical(lipgloss.Left, blocks...),
+		hint,
+	)
+}
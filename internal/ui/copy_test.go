@@ -0,0 +1,28 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"arcane/internal/models"
+)
+
+func TestCopyPromptAsTyped(t *testing.T) {
+	t.Setenv("SSH_TTY", "/dev/pts/0")
+	var out bytes.Buffer
+	m := newSearchModel(ChatMessage{Role: models.RoleUser, Content: "explain main.go\n📎 main.go", Prompt: "explain @main.go"})
+	m.Output = &out
+	if err := m.OpenCopySelector(); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := m.copySelected(0)
+	if err != nil || cmd == nil {
+		t.Fatalf("got %v, want the OSC 52 escape", err)
+	}
+	cmd()
+	if want := base64.StdEncoding.EncodeToString([]byte("explain @main.go")); !strings.Contains(out.String(), want) {
+		t.Errorf("wrote %q, want the prompt as typed", out.String())
+	}
+}
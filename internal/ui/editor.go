@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// EditorFinishedMsg carries the text saved in the external editor
+type EditorFinishedMsg struct {
+	Content string
+	Err     error
+}
+
+// editorCommand returns $VISUAL or $EDITOR split into arguments, so values
+// like "code --wait" work
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) > 0 {
+			return args
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// OpenEditor suspends the UI and opens text in the user's editor as a
+// temporary Markdown file. The saved file comes back as an EditorFinishedMsg.
+func (m *Model) OpenEditor(text string) (tea.Cmd, error) {
+	if m.Loading {
+		return nil, fmt.Errorf("wait for the current response to finish")
+	}
+	f, err := os.CreateTemp("", "arcane-prompt-*.md")
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	args := editorCommand()
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return EditorFinishedMsg{Err: fmt.Errorf("%s: %w (input left unchanged)", args[0], err)}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return EditorFinishedMsg{Err: err}
+		}
+		return EditorFinishedMsg{Content: strings.TrimRight(string(data), "\r\n")}
+	}), nil
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"arcane/internal/export"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExportChat writes the current chat to disk. args are "[md|json|html] [path]";
+// the default is Markdown in the working directory.
+func (m *Model) ExportChat(args string) (string, error) {
+	if m.DBErr != nil {
+		return "", m.DBErr
+	}
+	if m.DB == nil {
+		return "", fmt.Errorf("history database not initialized")
+	}
+	if m.CurrentChatID == 0 {
+		return "", fmt.Errorf("nothing to export yet")
+	}
+
+	format := "md"
+	path := ""
+	fields := strings.Fields(args)
+	if len(fields) > 0 {
+		format = fields[0]
+	}
+	if len(fields) > 1 {
+		path = strings.Join(fields[1:], " ")
+	}
+
+	t, err := export.Load(m.DB, m.CurrentChatID)
+	if err != nil {
+		return "", err
+	}
+	data, err := export.Render(t, format)
+	if err != nil {
+		return "", err
+	}
+
+	if path == "" {
+		path = export.FileName(m.CurrentChatID, format)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.WorkingDir, path)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -488,6 +488,24 @@ func ParseInlineToolCall(content string) (name string, argsJSON string, ok bool)
 	return name, argsJSON, true
 }
 
+// ToolCallsFromExecs converts executed tools into records for storage,
+// truncating large results the same way compaction does
+func ToolCallsFromExecs(execs []ToolExecRecord) []models.ToolCall {
+	if len(execs) == 0 {
+		return nil
+	}
+	calls := make([]models.ToolCall, 0, len(execs))
+	for _, e := range execs {
+		calls = append(calls, models.ToolCall{
+			Name:      e.Name,
+			Arguments: e.Args,
+			Summary:   e.Summary,
+			Result:    TruncateToolResult(e.Name, e.Result),
+		})
+	}
+	return calls
+}
+
 func LastToolResult(execs []ToolExecRecord, toolName string) (string, bool) {
 	for i := len(execs) - 1; i >= 0; i-- {
 		if execs[i].Name == toolName {
@@ -561,19 +579,6 @@ func CoerceAgentFinalContent(content string, execs []ToolExecRecord) string {
 	return content
 }
 
-func PromptPreview(s string) string {
-	s = strings.TrimSpace(s)
-	s = strings.ReplaceAll(s, "\r", " ")
-	s = strings.ReplaceAll(s, "\n", " ")
-	s = strings.Join(strings.Fields(s), " ")
-	const maxRunes = 500
-	r := []rune(s)
-	if len(r) > maxRunes {
-		return string(r[:maxRunes])
-	}
-	return s
-}
-
 func TruncateRunes(s string, max int) string {
 	if max <= 0 {
 		return ""
@@ -673,19 +678,37 @@ func FindModelByID(id string) (models.AIModel, int, bool) {
 	return models.AIModel{}, 0, false
 }
 
-func FormatUserMessage(content string, width int, isFirst bool) string {
-	label := styles.UserLabelStyle.Render("YOU")
-	msg := styles.UserMsgStyle.Width(width - 4).Render(content)
-	if isFirst {
-		return fmt.Sprintf("\n%s\n%s", label, msg)
+// FindModelByQuery matches a model by ID or case-insensitive name. Unlisted
+// IDs containing a provider prefix ("vendor/model") are accepted as-is.
+func FindModelByQuery(q string) (models.AIModel, int, bool) {
+	q = strings.TrimSpace(q)
+	if mdl, i, ok := FindModelByID(q); ok {
+		return mdl, i, true
+	}
+	for i, mdl := range AvailableModels {
+		if strings.EqualFold(mdl.Name, q) {
+			return mdl, i, true
+		}
 	}
-	return fmt.Sprintf("%s\n%s", label, msg)
+	if strings.Contains(q, "/") && !strings.ContainsAny(q, " \t") {
+		return models.AIModel{ID: q, Name: q, Provider: "Unknown"}, 0, true
+	}
+	return models.AIModel{}, 0, false
 }
 
-func FormatAIMessage(content string) string {
-	label := styles.AiLabelStyle.Render("ARCANE")
-	msg := styles.AiMsgStyle.Render(content)
-	return fmt.Sprintf("%s\n%s", label, msg)
+// FormatEvent renders a transcript event such as a mode switch
+func FormatEvent(content string) string {
+	return styles.BranchIndicatorStyle.Render("· " + content)
+}
+
+// FormatBranchIndicator shows which sibling branch a message belongs to,
+// with the keys that switch branches unless they are unbound
+func FormatBranchIndicator(index, count int, keys string) string {
+	text := fmt.Sprintf("‹ %d/%d ›", index+1, count)
+	if keys != "" {
+		text += " " + keys + " to switch"
+	}
+	return styles.BranchIndicatorStyle.Render(text)
 }
 
 func FormatToolActions(actions []models.ToolAction) string {
@@ -697,9 +720,3 @@ func FormatToolActions(actions []models.ToolAction) string {
 	}
 	return strings.Join(lines, "\n")
 }
-
-func FormatAIMessageWithTools(toolDisplay, content string) string {
-	label := styles.AiLabelStyle.Render("ARCANE")
-	msg := styles.AiMsgStyle.Render(content)
-	return fmt.Sprintf("%s\n%s\n%s", label, toolDisplay, msg)
-}
@@ -1,8 +1,12 @@
 package ui
 
 import (
+	"arcane/internal/config"
 	"arcane/internal/db"
 	"arcane/internal/models"
+	"arcane/internal/project"
+	"arcane/internal/styles"
+	"database/sql"
 	"fmt"
 	"os"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"golang.org/x/term"
 )
 
 // GetMaxContextTokens returns the context limit for the current model
@@ -35,8 +40,11 @@ func InitialModel() Model {
 		option.WithBaseURL("https://openrouter.ai/api/v1"),
 		option.WithHeader("HTTP-Referer", "https://github.com/broxdeez/arcane"), // Placeholder
 		option.WithHeader("X-Title", "Arcane CLI"),
+		option.WithMaxRetries(0), // Retries are handled by retry.Do so they can be shown in the UI
 	)
 
+	cfg, cfgErr := config.Load()
+
 	ti := textarea.New()
 	ti.Placeholder = "Type a message..."
 	ti.Prompt = "❯ "
@@ -45,17 +53,12 @@ func InitialModel() Model {
 	ti.MaxHeight = 6
 	ti.SetHeight(2)
 	ti.SetWidth(80)
-	ti.FocusedStyle.Prompt = lipgloss.NewStyle().Foreground(lipgloss.Color("#B39DDB")).Bold(true)
-	ti.BlurredStyle.Prompt = lipgloss.NewStyle().Foreground(lipgloss.Color("#B39DDB")).Bold(true)
-	ti.FocusedStyle.Placeholder = lipgloss.NewStyle().Foreground(lipgloss.Color("#545454"))
-	ti.BlurredStyle.Placeholder = lipgloss.NewStyle().Foreground(lipgloss.Color("#545454"))
 	ti.FocusedStyle.CursorLine = lipgloss.NewStyle()
 	ti.BlurredStyle.CursorLine = lipgloss.NewStyle()
 	ti.Focus()
 
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
-	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#B39DDB"))
 
 	vp := viewport.New(60, 15)
 
@@ -65,7 +68,19 @@ func InitialModel() Model {
 
 	mvp := viewport.New(ModalWidth-4, 15)
 
-	return Model{
+	keys, keysErr := NewKeyMap(cfg.Keys)
+
+	m := Model{
+		Session: Session{
+			ID:             1,
+			Messages:       []ChatMessage{},
+			History:        []openai.ChatCompletionMessageParamUnion{},
+			CurrentModel:   AvailableModels[0], // Gemini Flash as default
+			AppMode:        models.ModeChat,    // Start in chat mode by default
+			FocusedMessage: -1,
+		},
+		Tabs:               make([]Session, 1),
+		nextSessionID:      2,
 		TextInput:          ti,
 		Viewport:           vp,
 		ModelViewport:      mvp,
@@ -73,10 +88,7 @@ func InitialModel() Model {
 		Client:             client,
 		DB:                 dbConn,
 		DBErr:              dbErr,
-		CurrentChatID:      0,
-		History:            []openai.ChatCompletionMessageParamUnion{},
 		Renderer:           nil,
-		Messages:           []string{},
 		HistoryOpen:        false,
 		HistorySelectedIdx: 0,
 		HistoryChatCount:   0,
@@ -84,11 +96,32 @@ func InitialModel() Model {
 		HistoryErr:         nil,
 		HistoryPage:        0,
 		ModelSelectorOpen:  false,
-		CurrentModel:       AvailableModels[0], // Gemini Flash as default
-		SelectedModelIndex: 0,
-		AppMode:            models.ModeChat, // Start in chat mode by default
 		WorkingDir:         cwd,
+		GitRoot:            project.GitRoot(cwd),
+		Config:             cfg,
+		Keys:               keys,
+		InputHistoryIdx:    -1,
+		InputHistoryGlobal: cfg.InputHistoryScope == "global",
+	}
+	// The theme comes first so notes below are rendered in it
+	themeErr := m.LoadThemes()
+	if cfgErr != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Config error: %v", cfgErr)))
+	}
+	if keysErr != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Config error: %v", keysErr)))
+	}
+	if themeErr != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Theme error: %v", themeErr)))
 	}
+	if _, err := m.LoadInstructions(); err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Instructions error: %v", err)))
+	}
+	m.LoadTemplates()
+	if err := m.LoadInputHistory(); err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+	}
+	return m
 }
 
 func (m *Model) Init() tea.Cmd {
@@ -100,9 +133,72 @@ func (m *Model) Init() tea.Cmd {
 	)
 }
 
-func NewProgram() *tea.Program {
+// StartOptions selects a stored chat to open before the first frame
+type StartOptions struct {
+	ResumeChatID int64 // Open this chat
+	Continue     bool  // Open the most recently updated chat
+	ContinueHere bool  // With Continue, only consider chats from the current project
+}
+
+func NewProgram(opts StartOptions) (*tea.Program, error) {
 	m := InitialModel()
-	p := tea.NewProgram(&m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if err := m.resume(opts); err != nil {
+		if m.DB != nil {
+			_ = m.DB.Close()
+		}
+		return nil, err
+	}
+	m.Output = &terminalOutput{File: os.Stdout}
+	p := tea.NewProgram(&m, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithOutput(m.Output))
 	m.Program = p
-	return p
+	return p, nil
+}
+
+func (m *Model) resume(opts StartOptions) error {
+	if opts.ResumeChatID == 0 && !opts.Continue {
+		return nil
+	}
+	if m.DBErr != nil {
+		return m.DBErr
+	}
+
+	var chat models.ChatListItem
+	if opts.ResumeChatID != 0 {
+		var err error
+		chat, err = db.GetChat(m.DB, opts.ResumeChatID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("chat %d not found", opts.ResumeChatID)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		scope := ""
+		if opts.ContinueHere {
+			scope = m.ProjectKey()
+		}
+		var found bool
+		var err error
+		chat, found, err = db.GetLatestChat(m.DB, scope)
+		if err != nil {
+			return err
+		}
+		if !found && scope != "" {
+			return fmt.Errorf("no previous chat in %s", scope)
+		}
+		if !found {
+			return fmt.Errorf("no previous chat to continue")
+		}
+	}
+
+	// Lay out for the real terminal so the chat renders at the right width
+	// before the first frame
+	if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		m.Resize(w, h)
+	}
+	if err := m.LoadChatFromDB(chat); err != nil {
+		return err
+	}
+	m.Viewport.GotoBottom()
+	return nil
 }
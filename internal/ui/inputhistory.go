@@ -0,0 +1,226 @@
+package ui
+
+import (
+	"arcane/internal/db"
+	"arcane/internal/styles"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// inputHistoryLimit caps how many past inputs are loaded for recall
+const inputHistoryLimit = 1000
+
+// inputHistoryProject returns the project recall is scoped to, or "" for all
+func (m *Model) inputHistoryProject() string {
+	if m.InputHistoryGlobal {
+		return ""
+	}
+	return m.ProjectKey()
+}
+
+// LoadInputHistory reads past inputs for the current scope, newest first
+func (m *Model) LoadInputHistory() error {
+	m.InputHistory = nil
+	m.InputHistoryIdx = -1
+	if m.DB == nil {
+		return nil
+	}
+	entries, err := db.GetInputHistory(m.DB, m.inputHistoryProject(), inputHistoryLimit)
+	if err != nil {
+		return err
+	}
+	m.InputHistory = entries
+	return nil
+}
+
+// recordInput stores a line entered in the input box and resets recall
+func (m *Model) recordInput(text string) {
+	m.InputHistoryIdx = -1
+	if m.DB == nil || strings.TrimSpace(text) == "" {
+		return
+	}
+	if err := db.AddInputHistory(m.DB, text, m.ProjectKey(), time.Now().Unix()); err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+		return
+	}
+	entries := []string{text}
+	for _, e := range m.InputHistory {
+		if e != text {
+			entries = append(entries, e)
+		}
+	}
+	m.InputHistory = entries[:min(len(entries), inputHistoryLimit)]
+}
+
+// recallInput handles Up and Down in the input box. Up recalls an older
+// input when the input is empty or the cursor is on its first line; Down
+// goes back towards the draft from the last line. It reports whether the
+// key was used.
+func (m *Model) recallInput(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "up":
+		if m.TextInput.Value() != "" && m.TextInput.Line() > 0 {
+			return false
+		}
+		if m.InputHistoryIdx+1 >= len(m.InputHistory) {
+			return m.InputHistoryIdx >= 0
+		}
+		if m.InputHistoryIdx < 0 {
+			m.InputHistoryDraft = m.TextInput.Value()
+		}
+		m.InputHistoryIdx++
+		m.setInput(m.InputHistory[m.InputHistoryIdx])
+		return true
+	case "down":
+		if m.InputHistoryIdx < 0 || m.TextInput.Line() < m.TextInput.LineCount()-1 {
+			return false
+		}
+		m.InputHistoryIdx--
+		if m.InputHistoryIdx < 0 {
+			m.setInput(m.InputHistoryDraft)
+		} else {
+			m.setInput(m.InputHistory[m.InputHistoryIdx])
+		}
+		return true
+	}
+	return false
+}
+
+func (m *Model) setInput(text string) {
+	m.TextInput.SetValue(text)
+	m.updateInputLayout()
+	_, m.PendingFiles = ExtractFileMentions(text)
+}
+
+// OpenInputSearch starts a reverse search over past inputs
+func (m *Model) OpenInputSearch() {
+	m.InputSearchOpen = true
+	m.InputSearchQuery = ""
+	m.InputSearchIdx = -1
+	m.InputHistoryDraft = m.TextInput.Value()
+	m.FileSuggestOpen = false
+	m.CommandSuggestOpen = false
+}
+
+// searchInput finds the newest input at or after position from that
+// contains the query, ignoring case
+func (m *Model) searchInput(from int) {
+	q := strings.ToLower(m.InputSearchQuery)
+	for i := max(from, 0); i < len(m.InputHistory); i++ {
+		if strings.Contains(strings.ToLower(m.InputHistory[i]), q) {
+			m.InputSearchIdx = i
+			m.InputSearchFailed = false
+			return
+		}
+	}
+	m.InputSearchFailed = true
+}
+
+// handleInputSearchKey drives the reverse search like a shell's Ctrl+R
+func (m *Model) handleInputSearchKey(msg tea.KeyMsg) tea.Cmd {
+	if key.Matches(msg, m.Keys.SearchInput) {
+		if m.InputSearchQuery != "" {
+			m.searchInput(m.InputSearchIdx + 1)
+		}
+		return nil
+	}
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.InputSearchOpen = false
+		m.setInput(m.InputHistoryDraft)
+	case "enter", "tab", "right":
+		m.InputSearchOpen = false
+		if m.InputSearchIdx >= 0 && !m.InputSearchFailed {
+			m.setInput(m.InputHistory[m.InputSearchIdx])
+		}
+	case "up":
+		if m.InputSearchQuery != "" {
+			m.searchInput(m.InputSearchIdx + 1)
+		}
+	case "down":
+		// Newer match
+		q := strings.ToLower(m.InputSearchQuery)
+		for i := m.InputSearchIdx - 1; i >= 0; i-- {
+			if strings.Contains(strings.ToLower(m.InputHistory[i]), q) {
+				m.InputSearchIdx = i
+				break
+			}
+		}
+	case "ctrl+t":
+		m.InputHistoryGlobal = !m.InputHistoryGlobal
+		if err := m.LoadInputHistory(); err != nil {
+			m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+			m.UpdateViewport()
+		}
+		m.InputSearchIdx = -1
+		if m.InputSearchQuery != "" {
+			m.searchInput(0)
+		}
+	case "backspace":
+		if r := []rune(m.InputSearchQuery); len(r) > 0 {
+			m.InputSearchQuery = string(r[:len(r)-1])
+			m.InputSearchIdx = -1
+			m.InputSearchFailed = false
+			if m.InputSearchQuery != "" {
+				m.searchInput(0)
+			}
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.InputSearchQuery += string(msg.Runes)
+			if msg.Type == tea.KeySpace {
+				m.InputSearchQuery += " "
+			}
+			// Keep the current match while it still matches, as shells do
+			m.searchInput(m.InputSearchIdx)
+		}
+	}
+	return nil
+}
+
+// RenderInputSearch shows the search prompt and the current match above the input
+func (m *Model) RenderInputSearch() string {
+	if !m.InputSearchOpen {
+		return ""
+	}
+	scope := "this project"
+	if m.InputHistoryGlobal {
+		scope = "all projects"
+	}
+	label := "reverse-i-search"
+	if m.InputSearchFailed {
+		label = "failing reverse-i-search"
+	}
+	prompt := lipgloss.NewStyle().Foreground(styles.CurrentTheme.Secondary).
+		Render(fmt.Sprintf("(%s)`%s': ", label, m.InputSearchQuery))
+
+	match := ""
+	if m.InputSearchIdx >= 0 {
+		match = m.InputHistory[m.InputSearchIdx]
+		if first, _, multi := strings.Cut(match, "\n"); multi {
+			match = first + " …"
+		}
+		match = highlightMatch(TruncateRunes(match, max(10, m.WindowWidth-lipgloss.Width(prompt)-8)), m.InputSearchQuery)
+	}
+	hint := lipgloss.NewStyle().Foreground(styles.HintColor).
+		Render(fmt.Sprintf("%s: older • ↓: newer • Enter: use • Ctrl+T: %s • Esc: cancel", m.KeyHelp("search_input")+"/↑", scope))
+	return lipgloss.JoinVertical(lipgloss.Left, prompt+match, hint)
+}
+
+// highlightMatch underlines the first case-insensitive occurrence of query
+func highlightMatch(text, query string) string {
+	if query == "" {
+		return text
+	}
+	i := strings.Index(strings.ToLower(text), strings.ToLower(query))
+	if i < 0 || len(strings.ToLower(text)) != len(text) {
+		return text
+	}
+	hl := lipgloss.NewStyle().Underline(true).Foreground(styles.CurrentTheme.Highlight)
+	return text[:i] + hl.Render(text[i:i+len(query)]) + text[i+len(query):]
+}
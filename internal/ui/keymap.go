@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"arcane/internal/config"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap holds the global keybindings. Each can be overridden by action name
+// under "keys" in config.json.
+type KeyMap struct {
+	Quit         key.Binding
+	NewChat      key.Binding
+	NewTab       key.Binding
+	CloseTab     key.Binding
+	NextTab      key.Binding
+	PrevTab      key.Binding
+	ToggleMode   key.Binding
+	SelectModel  key.Binding
+	History      key.Binding
+	Palette      key.Binding
+	Shortcuts    key.Binding
+	Newline      key.Binding
+	Editor       key.Binding
+	SearchInput  key.Binding
+	Find         key.Binding
+	CopyMode     key.Binding
+	EditQueued   key.Binding
+	Regenerate   key.Binding
+	EditPrevious key.Binding
+	PrevBranch   key.Binding
+	NextBranch   key.Binding
+	ScrollUp     key.Binding
+	ScrollDown   key.Binding
+	PrevMessage  key.Binding
+	NextMessage  key.Binding
+	ToggleFold   key.Binding
+}
+
+// keyAction names a binding for config files and the shortcuts modal
+type keyAction struct {
+	Name    string
+	Binding *key.Binding
+}
+
+// reservedKeys are handled by the input box and popups and cannot be rebound
+var reservedKeys = []string{"enter", "tab", "esc", "backspace"}
+
+// DefaultKeyMap returns the built-in bindings. History is not on Ctrl+H by
+// default because many terminals send Ctrl+H for Backspace.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Quit:         newBinding("Quit application", "ctrl+c"),
+		NewChat:      newBinding("New chat session", "ctrl+n"),
+		NewTab:       newBinding("New tab", "ctrl+t"),
+		CloseTab:     newBinding("Close tab", "alt+w"),
+		NextTab:      newBinding("Next tab", "ctrl+pgdown", "alt+."),
+		PrevTab:      newBinding("Previous tab", "ctrl+pgup", "alt+,"),
+		ToggleMode:   newBinding("Toggle Agent/Chat mode", "ctrl+a"),
+		SelectModel:  newBinding("Select AI model", "ctrl+b"),
+		History:      newBinding("View chat history", "ctrl+o"),
+		Palette:      newBinding("Command palette", "ctrl+p"),
+		Shortcuts:    newBinding("View shortcuts (this menu)", "ctrl+s"),
+		Newline:      newBinding("New line in input", "shift+enter", "ctrl+j", "shift+return", "ctrl+enter", "alt+enter"),
+		Editor:       newBinding("Compose in $EDITOR", "ctrl+g"),
+		SearchInput:  newBinding("Search previous prompts", "ctrl+r"),
+		Find:         newBinding("Search this conversation", "ctrl+f"),
+		CopyMode:     newBinding("Copy a message or code block", "ctrl+y"),
+		EditQueued:   newBinding("Edit the last queued message", "alt+q"),
+		Regenerate:   newBinding("Regenerate last answer", "alt+r"),
+		EditPrevious: newBinding("Edit & resend a previous message", "alt+e"),
+		PrevBranch:   newBinding("Previous conversation branch", "ctrl+left"),
+		NextBranch:   newBinding("Next conversation branch", "ctrl+right"),
+		ScrollUp:     newBinding("Scroll chat up", "alt+up"),
+		ScrollDown:   newBinding("Scroll chat down", "alt+down"),
+		PrevMessage:  newBinding("Jump to previous message", "ctrl+up", "alt+k"),
+		NextMessage:  newBinding("Jump to next message", "ctrl+down", "alt+j"),
+		ToggleFold:   newBinding("Fold/unfold code, tool output and thinking", "alt+z"),
+	}
+}
+
+func newBinding(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(FormatKeys(keys), desc))
+}
+
+// actions lists the bindings in the order they are shown
+func (k *KeyMap) actions() []keyAction {
+	return []keyAction{
+		{"quit", &k.Quit},
+		{"new_chat", &k.NewChat},
+		{"new_tab", &k.NewTab},
+		{"close_tab", &k.CloseTab},
+		{"next_tab", &k.NextTab},
+		{"prev_tab", &k.PrevTab},
+		{"toggle_mode", &k.ToggleMode},
+		{"select_model", &k.SelectModel},
+		{"history", &k.History},
+		{"palette", &k.Palette},
+		{"shortcuts", &k.Shortcuts},
+		{"newline", &k.Newline},
+		{"editor", &k.Editor},
+		{"search_input", &k.SearchInput},
+		{"find", &k.Find},
+		{"copy_mode", &k.CopyMode},
+		{"edit_queued", &k.EditQueued},
+		{"regenerate", &k.Regenerate},
+		{"edit_previous", &k.EditPrevious},
+		{"prev_branch", &k.PrevBranch},
+		{"next_branch", &k.NextBranch},
+		{"scroll_up", &k.ScrollUp},
+		{"scroll_down", &k.ScrollDown},
+		{"prev_message", &k.PrevMessage},
+		{"next_message", &k.NextMessage},
+		{"toggle_fold", &k.ToggleFold},
+	}
+}
+
+// Binding returns the binding for an action name
+func (k *KeyMap) Binding(action string) (key.Binding, bool) {
+	for _, a := range k.actions() {
+		if a.Name == action {
+			return *a.Binding, true
+		}
+	}
+	return key.Binding{}, false
+}
+
+// NewKeyMap applies config overrides to the defaults. Overrides win over
+// defaults: a default key taken by another action moves to that action.
+// Unknown actions, reserved keys and keys given to two actions are reported
+// and the offending override is ignored.
+func NewKeyMap(overrides map[string]config.KeyList) (KeyMap, error) {
+	k := DefaultKeyMap()
+	actions := k.actions()
+	var errs []error
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Keys claimed by overrides, and by which action
+	claimed := map[string]string{}
+	overridden := map[string]bool{}
+	for _, name := range names {
+		idx := slices.IndexFunc(actions, func(a keyAction) bool { return a.Name == name })
+		if idx < 0 {
+			errs = append(errs, fmt.Errorf("keys: unknown action %q", name))
+			continue
+		}
+		keys := overrides[name]
+		if bad := slices.IndexFunc(keys, func(s string) bool { return slices.Contains(reservedKeys, s) }); bad >= 0 {
+			errs = append(errs, fmt.Errorf("keys: %s: %q is reserved", name, keys[bad]))
+			continue
+		}
+		if bad := slices.IndexFunc(keys, func(s string) bool { return claimed[s] != "" }); bad >= 0 {
+			errs = append(errs, fmt.Errorf("keys: %q is bound to both %s and %s", keys[bad], claimed[keys[bad]], name))
+			continue
+		}
+		for _, s := range keys {
+			claimed[s] = name
+		}
+		overridden[name] = true
+		setKeys(actions[idx].Binding, keys)
+	}
+
+	for _, a := range actions {
+		if overridden[a.Name] {
+			continue
+		}
+		keys := slices.DeleteFunc(slices.Clone(a.Binding.Keys()), func(s string) bool { return claimed[s] != "" })
+		if len(keys) == len(a.Binding.Keys()) {
+			continue
+		}
+		if len(keys) == 0 {
+			errs = append(errs, fmt.Errorf("keys: %s has no key left (%s is now %s)", a.Name, a.Binding.Keys()[0], claimed[a.Binding.Keys()[0]]))
+		}
+		setKeys(a.Binding, keys)
+	}
+	return k, errors.Join(errs...)
+}
+
+func setKeys(b *key.Binding, keys []string) {
+	desc := b.Help().Desc
+	if len(keys) == 0 {
+		b.Unbind()
+		b.SetHelp("", desc)
+		return
+	}
+	b.SetKeys(keys...)
+	b.SetEnabled(true)
+	b.SetHelp(FormatKeys(keys), desc)
+}
+
+// keyNames spells out keys for display
+var keyNames = map[string]string{
+	"ctrl": "Ctrl", "alt": "Alt", "shift": "Shift",
+	"enter": "Enter", "return": "Return", "space": "Space", "tab": "Tab", "esc": "Esc",
+	"up": "↑", "down": "↓", "left": "←", "right": "→",
+	"pgup": "PgUp", "pgdown": "PgDn", "home": "Home", "end": "End",
+}
+
+// FormatKeys renders bubbletea key names for display, e.g. "ctrl+b" as
+// "Ctrl+B". Only the first two keys are shown.
+func FormatKeys(keys []string) string {
+	var out []string
+	for _, k := range keys[:min(len(keys), 2)] {
+		parts := strings.Split(k, "+")
+		for i, p := range parts {
+			if name, ok := keyNames[p]; ok {
+				parts[i] = name
+			} else if p != "" {
+				parts[i] = strings.ToUpper(p[:1]) + p[1:]
+			}
+		}
+		out = append(out, strings.Join(parts, "+"))
+	}
+	return strings.Join(out, "/")
+}
+
+// KeyHelp returns the display form of the keys bound to the given actions,
+// leaving out unbound ones
+func (m *Model) KeyHelp(actions ...string) string {
+	var out []string
+	for _, action := range actions {
+		if b, ok := m.Keys.Binding(action); ok && b.Enabled() {
+			out = append(out, b.Help().Key)
+		}
+	}
+	return strings.Join(out, "/")
+}
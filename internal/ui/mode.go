@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"arcane/internal/db"
+	"arcane/internal/instructions"
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ModeName returns the display name of a mode
+func ModeName(mode models.AppMode) string {
+	if mode == models.ModeAgent {
+		return "Agent"
+	}
+	return "Chat"
+}
+
+// ResolveSystemPrompt builds the system prompt for the current mode, persona
+// and directory. Instruction files are added when sending, so edits to them
+// reach stored chats too.
+func (m *Model) ResolveSystemPrompt() string {
+	prompt := ChatSystemPrompt
+	if m.AppMode == models.ModeAgent {
+		prompt = fmt.Sprintf(AgentSystemPrompt, m.WorkingDir)
+	}
+	if p, ok := m.ActivePersona(); ok && p.SystemPrompt != "" {
+		prompt = strings.ReplaceAll(p.SystemPrompt, "{{cwd}}", m.WorkingDir)
+	}
+	return prompt
+}
+
+// LoadInstructions re-reads the instruction files. It reports whether they
+// changed since the last load.
+func (m *Model) LoadInstructions() (bool, error) {
+	files, err := instructions.Discover(m.WorkingDir, m.GitRoot)
+	if err != nil {
+		return false, err
+	}
+	stamp := instructions.Stamp(files)
+	if stamp == m.InstructionsStamp {
+		return false, nil
+	}
+	m.Instructions = files
+	m.InstructionsStamp = stamp
+	return true, nil
+}
+
+// reloadInstructions picks up edits to instruction files before a request
+// and notes the reload in the transcript
+func (m *Model) reloadInstructions() {
+	changed, err := m.LoadInstructions()
+	if err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Instructions: %v", err)))
+		return
+	}
+	if !changed {
+		return
+	}
+	note := "Instruction files removed"
+	if len(m.Instructions) > 0 {
+		note = "Instructions reloaded: " + m.InstructionNames()
+	}
+	m.AddNote(styles.InfoStyle(note))
+}
+
+// InstructionNames lists the loaded instruction files for display
+func (m *Model) InstructionNames() string {
+	names := make([]string, 0, len(m.Instructions))
+	for _, f := range m.Instructions {
+		names = append(names, instructions.DisplayPath(f.Path, m.ProjectKey()))
+	}
+	return strings.Join(names, ", ")
+}
+
+// basePrompt returns the prompt stored with the current chat, resolving it
+// on first use
+func (m *Model) basePrompt() string {
+	if m.SystemPrompt == "" {
+		m.SystemPrompt = m.ResolveSystemPrompt()
+	}
+	return m.SystemPrompt
+}
+
+// ActiveSystemPrompt returns the system prompt sent with requests: the
+// stored prompt followed by the current instruction files
+func (m *Model) ActiveSystemPrompt() string {
+	return m.basePrompt() + instructions.Prompt(m.Instructions, m.ProjectKey())
+}
+
+// SetMode switches between Chat and Agent mode. In a stored chat the switch
+// is recorded as an event in the transcript.
+func (m *Model) SetMode(mode models.AppMode) error {
+	if mode == m.AppMode {
+		return nil
+	}
+	m.AppMode = mode
+	m.SystemPrompt = ""
+	if m.CurrentChatID == 0 || m.DB == nil {
+		return nil
+	}
+
+	if err := m.addEvent(fmt.Sprintf("Switched to %s mode", ModeName(mode))); err != nil {
+		return err
+	}
+	return db.SetChatMode(m.DB, m.CurrentChatID, mode, m.basePrompt())
+}
+
+// addEvent stores a transcript event after the current leaf and shows it
+// when the session is in front
+func (m *Model) addEvent(content string) error {
+	if m.CurrentChatID != 0 && m.DB != nil {
+		id, err := db.InsertDBMessage(m.DB, m.CurrentChatID, models.DBMessage{
+			ParentID:      m.LeafMessageID,
+			Role:          models.RoleEvent,
+			Content:       content,
+			CreatedAtUnix: time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		m.LeafMessageID = id
+		m.refreshPath()
+	}
+	m.Messages = append(m.Messages, ChatMessage{Role: models.RoleEvent, Content: content, CreatedAt: time.Now()})
+	if m.inFront() {
+		m.UpdateViewport()
+		m.Viewport.GotoBottom()
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// NotifyErrMsg reports a notify command that failed
+type NotifyErrMsg struct{ Err error }
+
+// notifyCommandTimeout bounds how long a notify command may run
+const notifyCommandTimeout = 10 * time.Second
+
+// notifyDone announces the end of the session's request when it ran for at
+// least notify.after_seconds. err is nil for an answer. The returned command
+// sends the terminal notifications and runs the command hook.
+func (m *Model) notifyDone(err error) tea.Cmd {
+	cfg := m.Config.Notify
+	if m.RequestStart.IsZero() {
+		return nil
+	}
+	elapsed := time.Since(m.RequestStart).Round(time.Second)
+	m.RequestStart = time.Time{}
+	if elapsed < time.Duration(cfg.AfterSeconds)*time.Second || (len(cfg.Methods) == 0 && cfg.Command == "") {
+		return nil
+	}
+
+	title := "Arcane: " + chatTitle(m.Session, 40)
+	status, body := "done", fmt.Sprintf("Answer ready after %s", elapsed)
+	if err != nil {
+		status, body = "failed", fmt.Sprintf("Failed after %s: %v", elapsed, err)
+	}
+	body = TruncateRunes(body, 200)
+
+	var seq strings.Builder
+	for _, method := range cfg.Methods {
+		switch method {
+		case "bell":
+			seq.WriteString("\a")
+		case "osc9":
+			seq.WriteString(wrapPassthrough("\x1b]9;" + oscText(title+": "+body) + "\a"))
+		case "osc777":
+			seq.WriteString(wrapPassthrough("\x1b]777;notify;" + strings.ReplaceAll(oscText(title), ";", ",") + ";" + oscText(body) + "\a"))
+		}
+	}
+	escapes := m.writeTerminal(seq.String())
+
+	if cfg.Command == "" {
+		return escapes
+	}
+	env := append(os.Environ(),
+		"ARCANE_TITLE="+title,
+		"ARCANE_STATUS="+status,
+		"ARCANE_MESSAGE="+body,
+		"ARCANE_ELAPSED="+strconv.Itoa(int(elapsed.Seconds())),
+		"ARCANE_CHAT_ID="+strconv.FormatInt(m.CurrentChatID, 10),
+	)
+	command := cfg.Command
+	return tea.Batch(escapes, func() tea.Msg {
+		if err := runNotifyCommand(command, env); err != nil {
+			return NotifyErrMsg{Err: err}
+		}
+		return nil
+	})
+}
+
+// runNotifyCommand runs the user's notify hook with sh -c. Its output would
+// garble the screen, so only stderr is kept, for the error.
+func runNotifyCommand(command string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, TruncateRunes(msg, 200))
+		}
+		return err
+	}
+	return nil
+}
+
+// oscText drops control characters, which would end the escape sequence early
+func oscText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// wrapPassthrough lets an escape sequence through tmux or screen to the
+// outer terminal, as CopyToClipboard does for OSC 52
+func wrapPassthrough(seq string) string {
+	if os.Getenv("TMUX") != "" {
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return "\x1bP" + seq + "\x1b\\"
+	}
+	return seq
+}
@@ -0,0 +1,201 @@
+package ui
+
+import (
+	"arcane/internal/styles"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// paletteRows caps how many actions the palette shows at once
+const paletteRows = 12
+
+// OpenPalette shows the command palette with every action listed
+func (m *Model) OpenPalette() {
+	m.LoadTemplates()
+	m.PaletteOpen = true
+	m.PaletteQuery = ""
+	m.ModelSelectorOpen = false
+	m.HistoryOpen = false
+	m.ShortcutsOpen = false
+	m.PersonaSelectorOpen = false
+	m.TemplateSelectorOpen = false
+	m.filterPalette()
+}
+
+// filterPalette ranks the commands matching the query, best first
+func (m *Model) filterPalette() {
+	type scored struct {
+		cmd   Command
+		score int
+	}
+	var matches []scored
+	for _, c := range m.Commands() {
+		score, ok := fuzzyScore(m.PaletteQuery, c.Name)
+		if descScore, descOK := fuzzyScore(m.PaletteQuery, c.Description); descOK && (!ok || descScore/2 > score) {
+			score, ok = descScore/2, true
+		}
+		if ok {
+			matches = append(matches, scored{c, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	m.PaletteItems = m.PaletteItems[:0]
+	for _, s := range matches {
+		m.PaletteItems = append(m.PaletteItems, s.cmd)
+	}
+	m.PaletteIdx = 0
+}
+
+// fuzzyScore reports whether query is a subsequence of text, ignoring case.
+// Consecutive matches and matches at the start of a word score higher.
+func fuzzyScore(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+	score, qi, last := 0, 0, -2
+	for i, r := range t {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 4
+		}
+		if i == 0 || !unicode.IsLetter(t[i-1]) {
+			score += 3
+		}
+		last = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// runPaletteItem runs the selected action. Commands that need arguments are
+// put in the input to be completed instead.
+func (m *Model) runPaletteItem() tea.Cmd {
+	m.PaletteOpen = false
+	if m.PaletteIdx >= len(m.PaletteItems) {
+		return nil
+	}
+	c := m.PaletteItems[m.PaletteIdx]
+	if strings.Contains(c.Args, "<") && !c.Template {
+		m.TextInput.SetValue("/" + c.Name + " ")
+		m.updateInputLayout()
+		return nil
+	}
+	cmd, _ := m.execCommand(c, "")
+	return cmd
+}
+
+// handlePaletteKey edits the query and moves the selection
+func (m *Model) handlePaletteKey(msg tea.KeyMsg) tea.Cmd {
+	if key.Matches(msg, m.Keys.Quit) {
+		return tea.Quit
+	}
+	if key.Matches(msg, m.Keys.Palette) {
+		m.PaletteOpen = false
+		return nil
+	}
+	switch msg.String() {
+	case "esc":
+		m.PaletteOpen = false
+	case "enter":
+		return m.runPaletteItem()
+	case "up", "ctrl+k":
+		if n := len(m.PaletteItems); n > 0 {
+			m.PaletteIdx = (m.PaletteIdx - 1 + n) % n
+		}
+	case "down", "ctrl+j", "ctrl+n":
+		if n := len(m.PaletteItems); n > 0 {
+			m.PaletteIdx = (m.PaletteIdx + 1) % n
+		}
+	case "backspace":
+		if r := []rune(m.PaletteQuery); len(r) > 0 {
+			m.PaletteQuery = string(r[:len(r)-1])
+			m.filterPalette()
+		}
+	case "ctrl+u":
+		m.PaletteQuery = ""
+		m.filterPalette()
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			m.PaletteQuery += string(msg.Runes)
+			if msg.Type == tea.KeySpace {
+				m.PaletteQuery += " "
+			}
+			m.filterPalette()
+		}
+	}
+	return nil
+}
+
+func (m *Model) RenderPalette() string {
+	title := styles.ModalTitleStyle.Render("Command Palette")
+
+	query := lipgloss.NewStyle().Foreground(styles.CurrentTheme.Secondary).Render("› ") + m.PaletteQuery +
+		lipgloss.NewStyle().Foreground(styles.CurrentTheme.TextMuted).Render("▏")
+	if m.PaletteQuery == "" {
+		query += lipgloss.NewStyle().Foreground(styles.HintColor).Render("type to filter")
+	}
+
+	keyStyle := lipgloss.NewStyle().Foreground(styles.CurrentTheme.Highlight).Bold(true)
+	descStyle := lipgloss.NewStyle().Foreground(styles.CurrentTheme.TextMuted)
+
+	start := 0
+	if m.PaletteIdx >= paletteRows {
+		start = m.PaletteIdx - paletteRows + 1
+	}
+	end := min(start+paletteRows, len(m.PaletteItems))
+
+	var items []string
+	for i := start; i < end; i++ {
+		c := m.PaletteItems[i]
+		key := m.KeyHelp(c.Keys...)
+		if c.Template {
+			key = "template"
+		}
+		name := "/" + c.Name
+		room := styles.ContentWidth - lipgloss.Width(name) - lipgloss.Width(key) - 7
+		desc := ""
+		if room > 3 {
+			desc = TruncateRunes(c.Description, room)
+		}
+		gap := max(1, styles.ContentWidth-4-lipgloss.Width(name)-lipgloss.Width(desc)-lipgloss.Width(key)-2)
+
+		style := styles.ModalItemStyle.Copy().Width(styles.ContentWidth)
+		var line string
+		if i == m.PaletteIdx {
+			style = styles.ModalSelectedStyle.Copy().Width(styles.ContentWidth)
+			line = fmt.Sprintf("%s  %s%s%s", name, desc, strings.Repeat(" ", gap), key)
+		} else {
+			line = fmt.Sprintf("%s  %s%s%s", name, descStyle.Render(desc), strings.Repeat(" ", gap), keyStyle.Render(key))
+		}
+		items = append(items, style.Render(line))
+	}
+	if len(m.PaletteItems) == 0 {
+		items = append(items, lipgloss.NewStyle().Foreground(styles.HintColor).Render("  No matching actions"))
+	}
+
+	hint := lipgloss.NewStyle().
+		Foreground(styles.HintColor).
+		Width(styles.ContentWidth).
+		PaddingTop(1).
+		Render(fmt.Sprintf("↑/↓: navigate • Enter: run • Esc: close • %d/%d", min(m.PaletteIdx+1, len(m.PaletteItems)), len(m.PaletteItems)))
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, query, "", lipgloss.JoinVertical(lipgloss.Left, items...), hint)
+}
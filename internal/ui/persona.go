@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"arcane/internal/config"
+	"arcane/internal/db"
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"arcane/internal/tools"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ActivePersona returns the persona selected for the current chat, if it is
+// still defined in config
+func (m *Model) ActivePersona() (config.Persona, bool) {
+	if m.Persona == "" {
+		return config.Persona{}, false
+	}
+	return m.Config.Persona(m.Persona)
+}
+
+// AllowedTools returns the tools the active persona may use; nil allows all
+func (m *Model) AllowedTools() []string {
+	if p, ok := m.ActivePersona(); ok {
+		return p.Tools
+	}
+	return nil
+}
+
+// executeAllowedTool runs a tool unless the active persona does not allow it
+func executeAllowedTool(name, args string, allowed []string) (string, error) {
+	if !tools.IsAllowed(name, allowed) {
+		return "", fmt.Errorf("tool %q is not allowed for this persona (allowed: %s)", name, strings.Join(allowed, ", "))
+	}
+	return tools.ExecuteTool(name, args)
+}
+
+// SetPersona switches to a configured persona, or back to the built-in
+// prompts when name is empty. The persona's model and mode are applied and
+// the switch is recorded in the transcript of a stored chat.
+func (m *Model) SetPersona(name string) error {
+	if m.Loading {
+		return fmt.Errorf("wait for the current response to finish")
+	}
+	var p config.Persona
+	if name != "" {
+		var ok bool
+		if p, ok = m.Config.Persona(name); !ok {
+			return fmt.Errorf("unknown persona %q", name)
+		}
+		for _, t := range p.Tools {
+			if !slices.Contains(tools.Names(), t) {
+				return fmt.Errorf("persona %q: unknown tool %q (available: %s)", p.Name, t, strings.Join(tools.Names(), ", "))
+			}
+		}
+	}
+
+	m.Persona = p.Name
+	m.SystemPrompt = ""
+	if p.Model != "" {
+		m.SetModelByID(p.Model)
+	}
+
+	if m.CurrentChatID != 0 && m.DB != nil {
+		content := "Switched to the default persona"
+		if p.Name != "" {
+			content = fmt.Sprintf("Switched to persona %s", p.Name)
+		}
+		if err := m.addEvent(content); err != nil {
+			return err
+		}
+		if err := db.SetChatPersona(m.DB, m.CurrentChatID, m.Persona, m.CurrentModel.ID, m.basePrompt()); err != nil {
+			return err
+		}
+	}
+
+	switch p.Mode {
+	case "chat":
+		return m.SetMode(models.ModeChat)
+	case "agent":
+		return m.SetMode(models.ModeAgent)
+	}
+	return nil
+}
+
+// personaCommand handles "/persona [name|default]"; without a name it opens the picker
+func (m *Model) personaCommand(arg string) error {
+	switch strings.ToLower(arg) {
+	case "":
+		m.OpenPersonaSelector()
+		return nil
+	case "default", "none":
+		return m.SetPersona("")
+	}
+	return m.SetPersona(arg)
+}
+
+// OpenPersonaSelector shows the persona picker with the active persona selected
+func (m *Model) OpenPersonaSelector() {
+	m.PersonaSelectorOpen = true
+	m.ModelSelectorOpen = false
+	m.HistoryOpen = false
+	m.ShortcutsOpen = false
+	m.PersonaSelectedIdx = 0
+	for i, p := range m.Config.Personas {
+		if strings.EqualFold(p.Name, m.Persona) {
+			m.PersonaSelectedIdx = i + 1
+		}
+	}
+}
+
+// personaDetails summarises what a persona changes, e.g. "gpt-4o · agent · read, grep"
+func personaDetails(p config.Persona) string {
+	var parts []string
+	if p.Model != "" {
+		parts = append(parts, p.Model)
+	}
+	if p.Mode != "" {
+		parts = append(parts, p.Mode)
+	}
+	if len(p.Tools) > 0 {
+		parts = append(parts, strings.Join(p.Tools, ", "))
+	}
+	if p.Description != "" {
+		parts = append([]string{p.Description}, parts...)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (m *Model) RenderPersonaSelector() string {
+	title := styles.ModalTitleStyle.Render("Select Persona")
+
+	type entry struct{ name, details string }
+	entries := []entry{{"Default", "Built-in Chat and Agent prompts"}}
+	for _, p := range m.Config.Personas {
+		entries = append(entries, entry{p.Name, personaDetails(p)})
+	}
+
+	items := make([]string, 0, len(entries))
+	for i, e := range entries {
+		isCurrent := (i == 0 && m.Persona == "") || (i > 0 && strings.EqualFold(e.name, m.Persona))
+		name := "  " + e.name
+		if isCurrent {
+			name = "● " + e.name
+		}
+		details := lipgloss.NewStyle().Foreground(styles.HintColor).
+			Render("    " + TruncateRunes(e.details, styles.ContentWidth-6))
+
+		style := styles.ModalItemStyle.Copy().Width(styles.ContentWidth)
+		if i == m.PersonaSelectedIdx {
+			style = styles.ModalSelectedStyle.Copy().Width(styles.ContentWidth)
+		} else if isCurrent {
+			style = style.Foreground(styles.CurrentTheme.Secondary)
+		}
+		items = append(items, style.Render(name), details)
+	}
+	if len(m.Config.Personas) == 0 {
+		items = append(items, "", lipgloss.NewStyle().Foreground(styles.HintColor).Width(styles.ContentWidth).
+			Render("Define personas under \"personas\" in config.json"))
+	}
+
+	hint := lipgloss.NewStyle().
+		Foreground(styles.HintColor).
+		Width(styles.ContentWidth).
+		PaddingTop(1).
+		Render("↑/↓: navigate • Enter: select • Esc: close")
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, lipgloss.JoinVertical(lipgloss.Left, items...), hint)
+}
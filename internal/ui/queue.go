@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SteerMsg reports that the agent loop took a steering message between
+// tool iterations
+type SteerMsg struct{ Text string }
+
+// steerQueue hands steering messages to a running agent turn. The UI pushes
+// and the request goroutine drains, so it is shared by pointer.
+type steerQueue struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (q *steerQueue) push(text string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.msgs = append(q.msgs, text)
+}
+
+func (q *steerQueue) drain() []string {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msgs := q.msgs
+	q.msgs = nil
+	return msgs
+}
+
+// QueueInput holds a message typed while a response is generating. It is
+// sent when the turn ends.
+func (m *Model) QueueInput(input string) {
+	m.Queued = append(m.Queued, input)
+}
+
+// Steer passes a message to the running agent turn, which adds it to the
+// conversation before its next tool iteration
+func (m *Model) Steer(text string) error {
+	if !m.Loading || m.Steering == nil {
+		return fmt.Errorf("nothing is running; send it as a message instead")
+	}
+	if m.AppMode != models.ModeAgent {
+		return fmt.Errorf("only agent turns can be steered; use Enter to queue it")
+	}
+	m.Steering.push(text)
+	m.PendingSteers = append(m.PendingSteers, text)
+	return nil
+}
+
+// endTurn runs when a response finishes, fails or is cancelled. Steering
+// messages the agent did not get to are queued as follow-ups.
+func (m *Model) endTurn() {
+	if left := m.Steering.drain(); len(left) > 0 {
+		m.Queued = append(left, m.Queued...)
+	}
+	m.Steering = nil
+	m.PendingSteers = nil
+}
+
+// sendQueued sends the oldest queued message
+func (m *Model) sendQueued() tea.Cmd {
+	if len(m.Queued) == 0 || m.Loading {
+		return nil
+	}
+	input := m.Queued[0]
+	m.Queued = m.Queued[1:]
+	return m.sendInput(input)
+}
+
+// EditQueued takes the newest queued message back into an empty input
+func (m *Model) EditQueued() error {
+	if len(m.Queued) == 0 {
+		return fmt.Errorf("no queued messages")
+	}
+	if m.TextInput.Value() != "" {
+		return fmt.Errorf("clear the input first")
+	}
+	n := len(m.Queued)
+	m.setInput(m.Queued[n-1])
+	m.Queued = m.Queued[:n-1]
+	return nil
+}
+
+// addSteering shows a steering message the agent took and records it in
+// the chat as an event
+func (m *Model) addSteering(text string) error {
+	for i, s := range m.PendingSteers {
+		if s == text {
+			m.PendingSteers = append(m.PendingSteers[:i], m.PendingSteers[i+1:]...)
+			break
+		}
+	}
+	return m.addEvent("Steering: " + text)
+}
+
+// RenderQueue shows queued and pending steering messages as chips above
+// the input
+func (m *Model) RenderQueue() string {
+	if len(m.Queued) == 0 && len(m.PendingSteers) == 0 {
+		return ""
+	}
+	chipStyle := lipgloss.NewStyle().
+		Foreground(styles.CurrentTheme.OnColor).
+		Background(styles.CurrentTheme.Highlight).
+		Padding(0, 1).
+		MarginRight(1)
+	labelStyle := lipgloss.NewStyle().Foreground(styles.CurrentTheme.TextMuted)
+
+	chipText := func(s string) string {
+		first, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+		return TruncateRunes(first, 24)
+	}
+	var chips []string
+	for _, s := range m.PendingSteers {
+		chips = append(chips, chipStyle.Render("↳ "+chipText(s)))
+	}
+	for i, s := range m.Queued {
+		chips = append(chips, chipStyle.Render(fmt.Sprintf("%d %s", i+1, chipText(s))))
+	}
+
+	hint := "sent when this answer ends"
+	if !m.Loading {
+		hint = "Enter on an empty input sends the next"
+	}
+	if k := m.KeyHelp("edit_queued"); k != "" && len(m.Queued) > 0 {
+		hint += " • " + k + " edit last"
+	}
+	line := labelStyle.Render("Queued: ") + strings.Join(chips, "")
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.NewStyle().MaxWidth(m.WindowWidth-4).Render(line),
+		lipgloss.NewStyle().Foreground(styles.HintColor).Render("  "+hint))
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"arcane/internal/config"
+	"arcane/internal/styles"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/respjson"
+)
+
+// reasoningText returns the thinking a provider sent next to a message or a
+// stream delta. The API has no field for it: OpenRouter calls it
+// "reasoning", DeepSeek-style providers "reasoning_content".
+func reasoningText(extra map[string]respjson.Field) string {
+	for _, name := range []string{"reasoning", "reasoning_content"} {
+		field, ok := extra[name]
+		if !ok {
+			continue
+		}
+		var text string
+		if json.Unmarshal([]byte(field.Raw()), &text) == nil && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// reasoningEfforts returns the effort to request by model ID, from the config
+// with /effort overrides applied
+func (m *Model) reasoningEfforts() map[string]string {
+	efforts := maps.Clone(m.Config.ReasoningEffort)
+	if efforts == nil {
+		efforts = map[string]string{}
+	}
+	maps.Copy(efforts, m.ReasoningEffort)
+	return efforts
+}
+
+// withReasoningEffort asks for the given effort in OpenRouter's reasoning
+// object, which it translates for each provider. An empty effort leaves the
+// request unchanged.
+func withReasoningEffort(params openai.ChatCompletionNewParams, effort string) openai.ChatCompletionNewParams {
+	if effort != "" {
+		params.SetExtraFields(map[string]any{
+			"reasoning": map[string]any{"effort": effort},
+		})
+	}
+	return params
+}
+
+// SetReasoningEffort sets the effort for the current model for this session.
+// "default" goes back to the provider's default.
+func (m *Model) SetReasoningEffort(effort string) error {
+	if effort != "default" && !slices.Contains(config.ReasoningEfforts, effort) {
+		return fmt.Errorf("unknown effort %q (want %s or default)", effort, strings.Join(config.ReasoningEfforts, ", "))
+	}
+	if m.ReasoningEffort == nil {
+		m.ReasoningEffort = map[string]string{}
+	}
+	if effort == "default" {
+		effort = ""
+	}
+	m.ReasoningEffort[m.CurrentModel.ID] = effort
+	return nil
+}
+
+// reasoningEffortText describes the effort used for the current model
+func (m *Model) reasoningEffortText() string {
+	effort := m.reasoningEfforts()[m.CurrentModel.ID]
+	if effort == "" {
+		effort = "provider default"
+	}
+	return fmt.Sprintf("Reasoning effort for %s: %s", m.CurrentModel.Name, effort)
+}
+
+// formatReasoning shows a model's thinking above its answer, dimmed. Unless
+// unfolded is set it is folded to a line count.
+func (m *Model) formatReasoning(reasoning string, unfolded bool, width int) string {
+	reasoning = strings.TrimSpace(reasoning)
+	if reasoning == "" {
+		return ""
+	}
+	wrapped := styles.ReasoningStyle.Width(max(10, width-8)).Render(reasoning)
+	lines := strings.Split(wrapped, "\n")
+	if !unfolded {
+		hint := fmt.Sprintf("▸ Thinking · %d lines", len(lines))
+		if len(lines) == 1 {
+			hint = "▸ Thinking · 1 line"
+		}
+		if key := m.KeyHelp("toggle_fold"); key != "" {
+			hint += fmt.Sprintf(" (%s to expand)", key)
+		}
+		return styles.ToolActionStyle.Render(styles.ToolDetailStyle.Render(hint))
+	}
+	out := []string{styles.ToolActionStyle.Render(styles.ToolDetailStyle.Render("▾ Thinking"))}
+	for _, l := range lines {
+		out = append(out, styles.ToolActionStyle.Render(styles.ToolDetailStyle.Render("│ ")+l))
+	}
+	return strings.Join(out, "\n")
+}
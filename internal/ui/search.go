@@ -0,0 +1,362 @@
+package ui
+
+import (
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// chatSearchHit is the nth occurrence of the query in a message
+type chatSearchHit struct {
+	Msg int // Index into Messages
+	N   int
+}
+
+// Highlights for search matches. They are raw SGR sequences because they are
+// spliced into already rendered text.
+const (
+	matchOn  = "\x1b[7m"
+	sgrReset = "\x1b[0m"
+)
+
+// currentMatchOn starts the highlight of the current match in the theme's
+// highlight color, falling back to black on yellow when colors are off
+func currentMatchOn() string {
+	t := styles.CurrentTheme
+	on, _, _ := strings.Cut(lipgloss.NewStyle().Foreground(t.OnColor).Background(t.Highlight).Render("x"), "x")
+	if on == "" {
+		return "\x1b[30;43m"
+	}
+	return on
+}
+
+// OpenChatSearch starts typing a search over the conversation
+func (m *Model) OpenChatSearch(query string) {
+	m.ChatSearchOpen = true
+	m.ChatSearchEditing = true
+	m.ChatSearchQuery = query
+	m.FileSuggestOpen = false
+	m.CommandSuggestOpen = false
+	m.findInChat()
+}
+
+// CloseChatSearch ends the search and removes the highlights
+func (m *Model) CloseChatSearch() {
+	m.ChatSearchOpen = false
+	m.ChatSearchEditing = false
+	m.ChatSearchHits = nil
+	m.UpdateViewport()
+}
+
+// chatSearchHits finds the query in the content of prompts, answers and
+// events as it is shown, ignoring case and line wrapping. Folded content is
+// searched too.
+func (m *Model) chatSearchHits() []chatSearchHit {
+	if m.ChatSearchQuery == "" {
+		return nil
+	}
+	var hits []chatSearchHit
+	for i, msg := range m.Messages {
+		if msg.Role != models.RoleUser && msg.Role != models.RoleAssistant && msg.Role != models.RoleEvent {
+			continue
+		}
+		body := m.searchBody(i)
+		_, lines := highlightMatches(body, m.ChatSearchQuery, m.Messages[i].bodyMargin, -1)
+		for k := range lines {
+			hits = append(hits, chatSearchHit{Msg: i, N: k})
+		}
+	}
+	return hits
+}
+
+// findInChat re-runs the search and moves to the first hit at or below the
+// top of the chat, wrapping to the first one
+func (m *Model) findInChat() {
+	m.ChatSearchHits = m.chatSearchHits()
+	m.ChatSearchIdx = 0
+	for i, h := range m.ChatSearchHits {
+		if h.Msg < len(m.MessageLines) && m.MessageLines[h.Msg] >= m.Viewport.YOffset {
+			m.ChatSearchIdx = i
+			break
+		}
+	}
+	m.showSearchHit()
+}
+
+// stepSearch moves dir hits forward or back, wrapping around
+func (m *Model) stepSearch(dir int) {
+	n := len(m.ChatSearchHits)
+	if n == 0 {
+		return
+	}
+	m.ChatSearchIdx = (m.ChatSearchIdx + dir + n) % n
+	m.showSearchHit()
+}
+
+// showSearchHit scrolls the current hit into view, unfolding its message
+// when folding hid the hit or any match before it
+func (m *Model) showSearchHit() {
+	if len(m.ChatSearchHits) == 0 {
+		m.UpdateViewport()
+		return
+	}
+	hit := m.ChatSearchHits[m.ChatSearchIdx]
+	out := m.renderMessage(hit.Msg)
+	if msg := &m.Messages[hit.Msg]; msg.bodyFolded {
+		_, shown := highlightMatches(out[msg.bodyStart:msg.bodyEnd], m.ChatSearchQuery, msg.bodyMargin, -1)
+		_, full := highlightMatches(m.searchBody(hit.Msg), m.ChatSearchQuery, msg.bodyMargin, -1)
+		if len(shown) <= hit.N || !slices.Equal(shown[:hit.N+1], full[:hit.N+1]) {
+			msg.Unfolded = true
+			msg.rendered = ""
+		}
+	}
+	m.UpdateViewport()
+	m.Viewport.SetYOffset(m.ChatSearchLine - m.Viewport.Height/3)
+}
+
+// handleChatSearchKey drives the search prompt and, once the query is
+// entered, n/N to step through hits. It reports whether the key was used;
+// other keys end the search and are handled as usual.
+func (m *Model) handleChatSearchKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if key.Matches(msg, m.Keys.Quit) {
+		return tea.Quit, true
+	}
+	if m.ChatSearchEditing {
+		switch msg.String() {
+		case "esc":
+			m.CloseChatSearch()
+		case "enter":
+			m.ChatSearchEditing = false
+			if m.ChatSearchQuery == "" {
+				m.CloseChatSearch()
+			}
+		case "up", "shift+tab":
+			m.stepSearch(-1)
+		case "down", "tab":
+			m.stepSearch(1)
+		case "ctrl+u":
+			m.ChatSearchQuery = ""
+			m.findInChat()
+		case "backspace":
+			if r := []rune(m.ChatSearchQuery); len(r) > 0 {
+				m.ChatSearchQuery = string(r[:len(r)-1])
+				m.findInChat()
+			}
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				m.ChatSearchQuery += string(msg.Runes)
+				if msg.Type == tea.KeySpace {
+					m.ChatSearchQuery += " "
+				}
+				m.findInChat()
+			}
+		}
+		return nil, true
+	}
+
+	switch {
+	case msg.String() == "n":
+		m.stepSearch(1)
+	case msg.String() == "N":
+		m.stepSearch(-1)
+	case msg.String() == "/":
+		m.OpenChatSearch("")
+	case key.Matches(msg, m.Keys.Find):
+		m.ChatSearchEditing = true
+	case msg.String() == "esc":
+		m.CloseChatSearch()
+	default:
+		m.CloseChatSearch()
+		return nil, false
+	}
+	return nil, true
+}
+
+// RenderChatSearch shows the search prompt above the input
+func (m *Model) RenderChatSearch() string {
+	if !m.ChatSearchOpen {
+		return ""
+	}
+	query := m.ChatSearchQuery
+	if m.ChatSearchEditing {
+		query += "▋"
+	}
+	prompt := lipgloss.NewStyle().Foreground(styles.CurrentTheme.Secondary).Render("Find: ") + query
+
+	hint := "n/N: next/previous • /: new search • Esc: close"
+	if m.ChatSearchEditing {
+		hint = "↑/↓: previous/next • Enter: done • Esc: cancel"
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, prompt, "  ",
+		lipgloss.NewStyle().Foreground(styles.HintColor).Render(hint))
+}
+
+// ChatSearchStatus is the match counter for the bottom bar
+func (m *Model) ChatSearchStatus() string {
+	if !m.ChatSearchOpen || m.ChatSearchQuery == "" {
+		return ""
+	}
+	if len(m.ChatSearchHits) == 0 {
+		return "no matches"
+	}
+	return fmt.Sprintf("match %d/%d", m.ChatSearchIdx+1, len(m.ChatSearchHits))
+}
+
+// lowerRunes lowercases s rune by rune so positions line up with the
+// original runes
+func lowerRunes(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
+
+// highlightMatches marks case-insensitive occurrences of query in rendered
+// text and returns the line of each occurrence. Escape sequences and the
+// first margin cells of each line, a message's border and padding, are
+// skipped, and any run of spaces and line breaks matches a single space, so
+// phrases are found across wrapped lines. Occurrence current gets the
+// stronger highlight.
+func highlightMatches(text, query string, margin, current int) (string, []int) {
+	q := []rune(strings.Join(strings.Fields(lowerRunes(query)), " "))
+	if len(q) == 0 {
+		return text, nil
+	}
+
+	// Split into escape sequences and visible runes, and build the text to
+	// search. Each searched rune records the token it came from, or -1 for
+	// a gap with no space to highlight.
+	type token struct {
+		s   string
+		esc bool
+	}
+	var tokens []token
+	var plain []rune
+	var from, lineOf []int
+	line, col, gap := 0, 0, false
+	for i := 0; i < len(text); {
+		if n := escapeLen(text[i:]); n > 0 {
+			tokens = append(tokens, token{text[i : i+n], true})
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		tokens = append(tokens, token{text[i : i+size], false})
+		i += size
+		t := len(tokens) - 1
+
+		if r == '\n' {
+			line, col = line+1, 0
+		} else {
+			col++
+		}
+		if r == '\n' || col <= margin || unicode.IsSpace(r) {
+			if !gap {
+				plain, from, lineOf = append(plain, ' '), append(from, -1), append(lineOf, line)
+				gap = true
+			}
+			if r == ' ' && col > margin && from[len(from)-1] < 0 {
+				from[len(from)-1] = t
+			}
+			continue
+		}
+		plain, from, lineOf = append(plain, unicode.ToLower(r)), append(from, t), append(lineOf, line)
+		gap = false
+	}
+
+	// Non-overlapping matches, marking the tokens each one covers
+	inMatch := make([]int, len(tokens))
+	for i := range inMatch {
+		inMatch[i] = -1
+	}
+	var lines []int
+	for i := 0; i+len(q) <= len(plain); i++ {
+		if string(plain[i:i+len(q)]) != string(q) {
+			continue
+		}
+		for j := i; j < i+len(q); j++ {
+			if from[j] >= 0 {
+				inMatch[from[j]] = len(lines)
+			}
+		}
+		lines = append(lines, lineOf[i])
+		i += len(q) - 1
+	}
+	if len(lines) == 0 {
+		return text, nil
+	}
+
+	var b strings.Builder
+	currentOn := currentMatchOn()
+	on := func(k int) string {
+		if k == current {
+			return currentOn
+		}
+		return matchOn
+	}
+	// Ending a highlight resets attributes, then restores the ones the text
+	// had set so colors carry on past the match
+	var active strings.Builder
+	in := -1
+	for i, t := range tokens {
+		if t.esc {
+			b.WriteString(t.s)
+			if strings.HasSuffix(t.s, "m") && strings.HasPrefix(t.s, "\x1b[") {
+				if params := t.s[2 : len(t.s)-1]; params == "" || params == "0" || strings.HasPrefix(params, "0;") {
+					active.Reset()
+				}
+				active.WriteString(t.s)
+			}
+			if in >= 0 {
+				// Re-apply the highlight after styles inside the match
+				b.WriteString(on(in))
+			}
+			continue
+		}
+		if inMatch[i] != in {
+			if in >= 0 {
+				b.WriteString(sgrReset + active.String())
+			}
+			if inMatch[i] >= 0 {
+				b.WriteString(on(inMatch[i]))
+			}
+			in = inMatch[i]
+		}
+		b.WriteString(t.s)
+	}
+	if in >= 0 {
+		b.WriteString(sgrReset + active.String())
+	}
+	return b.String(), lines
+}
+
+// escapeLen returns the length of the CSI or OSC escape sequence at the
+// start of s, or 0
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' {
+		return 0
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	}
+	return 0
+}
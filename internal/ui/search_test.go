@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"arcane/internal/models"
+
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+func newSearchModel(msgs ...ChatMessage) *Model {
+	m := &Model{}
+	m.Viewport = viewport.New(80, 10)
+	m.Keys = DefaultKeyMap()
+	m.FocusedMessage = -1
+	m.Messages = msgs
+	return m
+}
+
+func TestSearchSkipsLabelsAndIndicators(t *testing.T) {
+	m := newSearchModel(
+		ChatMessage{Role: models.RoleUser, Content: "Are you there?"},
+		ChatMessage{Role: models.RoleAssistant, Content: "Arcane is here", SiblingIndex: 1, SiblingCount: 2},
+	)
+	for query, want := range map[string]int{"you": 1, "arcane": 1, "switch": 0} {
+		m.OpenChatSearch(query)
+		if got := len(m.ChatSearchHits); got != want {
+			t.Errorf("%q: got %d hits, want %d", query, got, want)
+		}
+		if got := strings.Count(m.transcript(), matchOn) + strings.Count(m.transcript(), currentMatchOn()); got != want {
+			t.Errorf("%q: %d matches highlighted, want %d", query, got, want)
+		}
+	}
+}
+
+func TestSearchUnfoldsHiddenHit(t *testing.T) {
+	var code []string
+	for i := range codeFoldLines + 5 {
+		code = append(code, fmt.Sprintf("line %d", i))
+	}
+	code[codeFoldLines] = "needle"
+	m := newSearchModel(
+		ChatMessage{Role: models.RoleUser, Content: "show me"},
+		ChatMessage{Role: models.RoleAssistant, Content: "```\n" + strings.Join(code, "\n") + "\n```\nneedle"},
+	)
+
+	m.OpenChatSearch("needle")
+	if len(m.ChatSearchHits) != 2 {
+		t.Fatalf("got %d hits, want the folded one and the one after the code", len(m.ChatSearchHits))
+	}
+	if m.ChatSearchIdx != 0 || !m.Messages[1].Unfolded {
+		t.Fatal("the first hit is folded away, want its message unfolded")
+	}
+	lines := strings.Split(m.transcript(), "\n")
+	if !strings.Contains(lines[m.ChatSearchLine], currentMatchOn()+"needle") {
+		t.Errorf("line %d is %q, want the current hit", m.ChatSearchLine, lines[m.ChatSearchLine])
+	}
+
+	m.stepSearch(1)
+	lines = strings.Split(m.transcript(), "\n")
+	if !strings.Contains(lines[m.ChatSearchLine], currentMatchOn()+"needle") {
+		t.Errorf("line %d is %q, want the second hit", m.ChatSearchLine, lines[m.ChatSearchLine])
+	}
+}
+
+func TestSearchAcrossWrappedLines(t *testing.T) {
+	m := newSearchModel(
+		ChatMessage{Role: models.RoleUser, Content: strings.Repeat("word ", 8) + "crossing the boundary"},
+		ChatMessage{Role: models.RoleAssistant, Content: "Some **bold** text, then filler filler filler wrapped phrase"},
+	)
+	m.Viewport.Width = 50
+	if err := m.newRenderer(46); err != nil {
+		t.Fatal(err)
+	}
+	for i, phrase := range []string{"word crossing", "filler wrapped"} {
+		if out := m.renderMessage(i); strings.Contains(out, phrase) {
+			t.Fatalf("want %q split by wrapping in %q", phrase, out)
+		}
+	}
+
+	for query, want := range map[string]int{"word crossing": 1, "filler  wrapped phrase": 1, "bold text": 1, "boundary some": 0, "┃": 0} {
+		m.OpenChatSearch(query)
+		if got := len(m.ChatSearchHits); got != want {
+			t.Errorf("%q: got %d hits, want %d", query, got, want)
+		}
+	}
+
+	m.OpenChatSearch("word crossing")
+	lines := strings.Split(m.transcript(), "\n")
+	if !strings.Contains(lines[m.ChatSearchLine], currentMatchOn()+"word") {
+		t.Errorf("line %d is %q, want the start of the hit", m.ChatSearchLine, lines[m.ChatSearchLine])
+	}
+}
+
+func TestHighlightRestoresTextStyle(t *testing.T) {
+	const green, bg = "\x1b[32m", "\x1b[48;5;236m"
+	text := green + bg + "a needle in code" + sgrReset
+	got, lines := highlightMatches(text, "needle", 0, 0)
+	if len(lines) != 1 {
+		t.Fatalf("got %d matches, want 1", len(lines))
+	}
+	want := green + bg + "a " + currentMatchOn() + "needle" + sgrReset + green + bg + " in code" + sgrReset
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"arcane/internal/config"
+	"arcane/internal/models"
+	"arcane/internal/retry/retrytest"
+)
+
+// newTestModel returns a model talking to srv, retrying twice per model
+// without waiting and failing over from "primary" to "fallback"
+func newTestModel(srv *retrytest.Server, mode models.AppMode) *Model {
+	m := &Model{}
+	m.Client = srv.Client()
+	m.Config = config.Default()
+	m.Config.Retry = config.RetryConfig{MaxAttempts: 2, BaseDelayMs: 1, MaxDelayMs: 2}
+	m.Config.FallbackModels = []string{"fallback"}
+	m.CurrentModel = models.AIModel{ID: "primary", Name: "Primary"}
+	m.AppMode = mode
+	m.SystemPrompt = "You are a test."
+	return m
+}
+
+// send runs SendMessage to completion and returns what it reported
+func send(t *testing.T, m *Model) any {
+	t.Helper()
+	msg := m.SendMessage(context.Background(), "hi")()
+	tab, ok := msg.(TabMsg)
+	if !ok {
+		t.Fatalf("got %T, want a TabMsg", msg)
+	}
+	if tab.Session != m.ID {
+		t.Errorf("tagged with session %d, want %d", tab.Session, m.ID)
+	}
+	return tab.Msg
+}
+
+func TestSendMessageRestartsBrokenStream(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Body: retrytest.BrokenStream("Provider overloaded", "Half ", "an")},
+		retrytest.Reply{Body: retrytest.Stream("Whole ", "answer")},
+	)
+	resp, ok := send(t, newTestModel(srv, models.ModeChat)).(ResponseMsg)
+	if !ok {
+		t.Fatal("want a ResponseMsg")
+	}
+	if resp.Content != "Whole answer" || resp.ModelID != "primary" {
+		t.Errorf("got %q from %q, want the second stream from primary", resp.Content, resp.ModelID)
+	}
+	if len(resp.History) != 2 {
+		t.Errorf("history has %d messages, want the prompt and one answer", len(resp.History))
+	}
+}
+
+func TestSendMessageRetriesRateLimit(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "1"}, Body: retrytest.Error("rate limited")},
+		retrytest.Reply{Body: retrytest.Stream("ok")},
+	)
+	m := newTestModel(srv, models.ModeChat)
+	m.Config.Retry.MaxDelayMs = 2000
+	resp, ok := send(t, m).(ResponseMsg)
+	if !ok || resp.Content != "ok" {
+		t.Fatalf("got %+v, want the answer after the retry", resp)
+	}
+	calls := srv.Calls()
+	if gap := calls[1].At.Sub(calls[0].At).Seconds(); gap < 1 {
+		t.Errorf("retried after %.2fs, want the 1s Retry-After honoured", gap)
+	}
+}
+
+func TestSendMessageFailsOver(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusBadGateway, Body: retrytest.Error("down")},
+		retrytest.Reply{Status: http.StatusServiceUnavailable, Body: retrytest.Error("down")},
+		retrytest.Reply{Body: retrytest.Stream("from fallback")},
+	)
+	resp, ok := send(t, newTestModel(srv, models.ModeChat)).(ResponseMsg)
+	if !ok {
+		t.Fatal("want a ResponseMsg")
+	}
+	if resp.Content != "from fallback" || resp.ModelID != "fallback" {
+		t.Errorf("got %q from %q, want the fallback's answer", resp.Content, resp.ModelID)
+	}
+	if got := fmt.Sprint(srv.Models()); got != "[primary primary fallback]" {
+		t.Errorf("requests went to %s", got)
+	}
+}
+
+func TestSendMessageAgentRetriesServerError(t *testing.T) {
+	srv := retrytest.NewServer(t,
+		retrytest.Reply{Status: http.StatusInternalServerError, Body: retrytest.Error("oops")},
+		retrytest.Reply{Body: retrytest.Completion("agent answer")},
+	)
+	resp, ok := send(t, newTestModel(srv, models.ModeAgent)).(ResponseMsg)
+	if !ok {
+		t.Fatal("want a ResponseMsg")
+	}
+	if resp.Content != "agent answer" || resp.PromptTokens != 3 || resp.CompletionTokens != 2 {
+		t.Errorf("got %+v", resp)
+	}
+	for _, c := range srv.Calls() {
+		if c.Stream {
+			t.Error("agent mode should not stream")
+		}
+	}
+}
+
+func TestSendMessageReportsFinalError(t *testing.T) {
+	down := retrytest.Reply{Status: http.StatusServiceUnavailable, Body: retrytest.Error("down")}
+	srv := retrytest.NewServer(t, down, down, down, down)
+	msg := send(t, newTestModel(srv, models.ModeChat))
+	if _, ok := msg.(ErrMsg); !ok {
+		t.Fatalf("got %T, want ErrMsg", msg)
+	}
+	if n := len(srv.Calls()); n != 4 {
+		t.Errorf("got %d requests, want 2 per model", n)
+	}
+}
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/openai/openai-go/v3"
+)
+
+// TabMsg carries a message from a request to the session that started it
+type TabMsg struct {
+	Session int // Session.ID
+	Msg     tea.Msg
+}
+
+// tabIndex returns the tab holding the session, or -1 if it was closed
+func (m *Model) tabIndex(id int) int {
+	if id == m.ID {
+		return m.ActiveTab
+	}
+	for i, s := range m.Tabs {
+		if s.ID == id && i != m.ActiveTab {
+			return i
+		}
+	}
+	return -1
+}
+
+// inFront reports whether the session in m is the one on screen rather than
+// a background tab swapped in by routeTabMsg
+func (m *Model) inFront() bool {
+	return len(m.Tabs) == 0 || m.Tabs[m.ActiveTab].ID == m.ID
+}
+
+// routeTabMsg applies a request's message to its session. For a background
+// tab the session is swapped in while its state is updated; the viewport
+// and search belong to the active tab and are left alone.
+func (m *Model) routeTabMsg(msg TabMsg) tea.Cmd {
+	if msg.Session == m.ID {
+		_, cmd := m.Update(msg.Msg)
+		return cmd
+	}
+	i := m.tabIndex(msg.Session)
+	if i < 0 {
+		return nil
+	}
+
+	active := m.Session
+	m.Session = m.Tabs[i]
+	cmd := m.applyRequestMsg(msg.Msg)
+	switch msg.Msg.(type) {
+	case ResponseMsg, ErrMsg:
+		m.Unread = true
+	}
+	m.Tabs[i] = m.Session
+	m.Session = active
+	return cmd
+}
+
+// saveTab stores the active session, with its scroll position and draft
+func (m *Model) saveTab() {
+	m.ScrollOffset = m.Viewport.YOffset
+	m.Draft = m.TextInput.Value()
+	m.Tabs[m.ActiveTab] = m.Session
+}
+
+// showTab makes tab i the active one
+func (m *Model) showTab(i int) {
+	m.ActiveTab = i
+	m.Session = m.Tabs[i]
+	m.Unread = false
+	m.setInput(m.Draft)
+	m.Draft = ""
+	m.ChatSearchOpen = false
+	m.ChatSearchEditing = false
+	m.FileSuggestOpen = false
+	m.CommandSuggestOpen = false
+	m.InputHistoryIdx = -1
+	m.UpdateViewport()
+	m.Viewport.SetYOffset(m.ScrollOffset)
+}
+
+// NewTab opens an empty chat in a tab after the current one. Like a new
+// chat, it keeps the current model, mode and persona.
+func (m *Model) NewTab() {
+	m.saveTab()
+	s := Session{
+		ID:                 m.nextSessionID,
+		History:            []openai.ChatCompletionMessageParamUnion{},
+		CurrentModel:       m.CurrentModel,
+		SelectedModelIndex: m.SelectedModelIndex,
+		AppMode:            m.AppMode,
+		Persona:            m.Persona,
+		FocusedMessage:     -1,
+	}
+	m.nextSessionID++
+	i := m.ActiveTab + 1
+	m.Tabs = append(m.Tabs[:i], append([]Session{s}, m.Tabs[i:]...)...)
+	m.showTab(i)
+}
+
+// CloseTab closes the active tab, cancelling its request
+func (m *Model) CloseTab() error {
+	if len(m.Tabs) == 1 {
+		return fmt.Errorf("this is the only tab")
+	}
+	if m.CancelFn != nil {
+		m.CancelFn()
+	}
+	i := m.ActiveTab
+	m.Tabs = append(m.Tabs[:i], m.Tabs[i+1:]...)
+	m.showTab(min(i, len(m.Tabs)-1))
+	return nil
+}
+
+// SwitchTab moves dir tabs to the right, wrapping around
+func (m *Model) SwitchTab(dir int) {
+	n := len(m.Tabs)
+	if n == 1 {
+		return
+	}
+	m.saveTab()
+	m.showTab(((m.ActiveTab+dir)%n + n) % n)
+}
+
+// GotoTab shows tab n, counting from 1
+func (m *Model) GotoTab(n int) error {
+	if n < 1 || n > len(m.Tabs) {
+		return fmt.Errorf("no tab %d (there are %d)", n, len(m.Tabs))
+	}
+	if n-1 != m.ActiveTab {
+		m.saveTab()
+		m.showTab(n - 1)
+	}
+	return nil
+}
+
+// chatTitle names a session after the first prompt of its chat, cut to n runes
+func chatTitle(s Session, n int) string {
+	for _, msg := range s.Messages {
+		if msg.Role == models.RoleUser {
+			first, _, _ := strings.Cut(strings.TrimSpace(msg.Content), "\n")
+			return TruncateRunes(first, n)
+		}
+	}
+	return "New chat"
+}
+
+// RenderTabBar lists the tabs, marking background tabs that are still
+// working or have an answer waiting. It is empty with a single tab.
+func (m *Model) RenderTabBar() string {
+	if len(m.Tabs) < 2 {
+		return ""
+	}
+	var tabs []string
+	for i, s := range m.Tabs {
+		style := styles.TabStyle
+		if i == m.ActiveTab {
+			s = m.Session
+			style = styles.ActiveTabStyle
+		}
+		label := fmt.Sprintf("%d %s", i+1, chatTitle(s, 20))
+		switch {
+		case s.Loading:
+			label += " " + strings.TrimSpace(m.Spinner.View())
+		case s.Unread:
+			label += " " + lipgloss.NewStyle().Foreground(styles.CurrentTheme.Highlight).Render("●")
+		}
+		tabs = append(tabs, style.Render(label))
+	}
+	bar := lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+	if lipgloss.Width(bar) > m.WindowWidth-2 {
+		// Too many tabs to fit: keep the active one and its neighbours
+		start := max(0, m.ActiveTab-2)
+		bar = lipgloss.JoinHorizontal(lipgloss.Top, append([]string{styles.TabStyle.Render("‹")}, tabs[start:]...)...)
+	}
+	return lipgloss.NewStyle().MaxWidth(m.WindowWidth - 2).Render(bar)
+}
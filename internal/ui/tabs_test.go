@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"arcane/internal/models"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestBackgroundTabLeavesActiveViewAlone(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(bg *Session)
+		msgs  []tea.Msg
+		check func(t *testing.T, bg Session)
+	}{
+		{
+			name: "answer failing",
+			msgs: []tea.Msg{StreamChunkMsg{Delta: "needle "}, ErrMsg(errors.New("needle failed"))},
+			check: func(t *testing.T, bg Session) {
+				if bg.Loading || !bg.Unread || bg.StreamingContent != "" || len(bg.Messages) != 2 {
+					t.Errorf("loading %v, unread %v, %d messages", bg.Loading, bg.Unread, len(bg.Messages))
+				}
+			},
+		},
+		{
+			name:  "steering taken",
+			setup: func(bg *Session) { bg.PendingSteers = []string{"more needles"} },
+			msgs:  []tea.Msg{SteerMsg{Text: "more needles"}},
+			check: func(t *testing.T, bg Session) {
+				if len(bg.PendingSteers) != 0 || bg.Messages[len(bg.Messages)-1].Content != "Steering: more needles" {
+					t.Errorf("steering not recorded: %d pending, %+v", len(bg.PendingSteers), bg.Messages)
+				}
+			},
+		},
+		{
+			name:  "queued message sent",
+			setup: func(bg *Session) { bg.Queued = []string{"next needle"} },
+			msgs:  []tea.Msg{ResponseMsg{Content: "a needle"}},
+			check: func(t *testing.T, bg Session) {
+				if !bg.Loading || len(bg.Queued) != 0 || !slices.ContainsFunc(bg.Messages, func(msg ChatMessage) bool {
+					return msg.Role == models.RoleUser && msg.Content == "next needle"
+				}) {
+					t.Errorf("queued message not sent: loading %v, %d queued", bg.Loading, len(bg.Queued))
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newSearchModel(
+				ChatMessage{Role: models.RoleUser, Content: "find the needle"},
+				ChatMessage{Role: models.RoleAssistant, Content: "one needle, two needle"},
+			)
+			m.TextInput = textarea.New()
+			m.Tabs = make([]Session, 1)
+			m.nextSessionID = 1
+			m.NewTab()
+			m.Messages = []ChatMessage{{Role: models.RoleUser, Content: "needle again"}}
+			m.Loading = true
+			if tt.setup != nil {
+				tt.setup(&m.Session)
+			}
+			m.SwitchTab(-1)
+
+			m.OpenChatSearch("needle")
+			m.stepSearch(1)
+			hits, idx, offset := len(m.ChatSearchHits), m.ChatSearchIdx, m.Viewport.YOffset
+			view := m.Viewport.View()
+
+			for _, msg := range tt.msgs {
+				m.routeTabMsg(TabMsg{Session: m.Tabs[1].ID, Msg: msg})
+			}
+
+			if len(m.ChatSearchHits) != hits || m.ChatSearchIdx != idx {
+				t.Errorf("search is at %d/%d, want %d/%d", m.ChatSearchIdx, len(m.ChatSearchHits), idx, hits)
+			}
+			if m.Viewport.YOffset != offset || m.Viewport.View() != view {
+				t.Error("the viewport changed for a background tab")
+			}
+			tt.check(t, m.Tabs[1])
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"arcane/internal/project"
+	"arcane/internal/styles"
+	"arcane/internal/templates"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LoadTemplates re-reads prompt templates from the config and project dirs.
+// A load error is reported once until it changes.
+func (m *Model) LoadTemplates() {
+	tmpls, err := templates.Load(m.ProjectKey())
+	m.Templates = tmpls
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	if msg != "" && msg != m.TemplatesErr {
+		m.AddNote(styles.ErrorStyle.Render("Templates: " + msg))
+	}
+	m.TemplatesErr = msg
+}
+
+// templateCommands turns templates into slash commands. Templates named
+// after a built-in command are only reachable from the picker.
+func (m *Model) templateCommands() []Command {
+	var cmds []Command
+	for _, t := range m.Templates {
+		if _, taken := findCommand(builtinCommands, t.Name); taken {
+			continue
+		}
+		args := ""
+		if vars := t.Vars(); len(vars) > 0 {
+			args = "<" + strings.Join(vars, "> <") + ">"
+		}
+		cmds = append(cmds, Command{
+			Name:        t.Name,
+			Args:        args,
+			Description: t.Description,
+			Template:    true,
+			Run: func(m *Model, args string) (tea.Cmd, error) {
+				return nil, m.ExpandTemplate(t, args)
+			},
+		})
+	}
+	return cmds
+}
+
+// ExpandTemplate fills in a template and puts the result in the input box
+// for editing before it is sent
+func (m *Model) ExpandTemplate(t templates.Template, args string) error {
+	text, err := t.Expand(args, map[string]string{
+		"cwd":     m.WorkingDir,
+		"date":    time.Now().Format("2006-01-02"),
+		"project": project.Name(m.WorkingDir, m.GitRoot),
+	})
+	if err != nil {
+		return err
+	}
+	m.TextInput.SetValue(text)
+	m.updateInputLayout()
+	return nil
+}
+
+// OpenTemplateSelector shows the template picker
+func (m *Model) OpenTemplateSelector() {
+	m.LoadTemplates()
+	m.TemplateSelectorOpen = true
+	m.TemplateSelectedIdx = 0
+	m.ModelSelectorOpen = false
+	m.HistoryOpen = false
+	m.ShortcutsOpen = false
+	m.PersonaSelectorOpen = false
+}
+
+func (m *Model) RenderTemplateSelector() string {
+	title := styles.ModalTitleStyle.Render(fmt.Sprintf("Prompt Templates (%d)", len(m.Templates)))
+
+	var items []string
+	for i, t := range m.Templates {
+		source := "user"
+		if t.Project {
+			source = "project"
+		}
+		name := fmt.Sprintf("  %s  [%s]", t.Name, source)
+		style := styles.ModalItemStyle.Copy().Width(styles.ContentWidth)
+		if i == m.TemplateSelectedIdx {
+			style = styles.ModalSelectedStyle.Copy().Width(styles.ContentWidth)
+		}
+		details := t.Description
+		if vars := t.Vars(); len(vars) > 0 {
+			details += " · {{" + strings.Join(vars, "}} {{") + "}}"
+		}
+		items = append(items, style.Render(name), lipgloss.NewStyle().Foreground(styles.HintColor).
+			Render("    "+TruncateRunes(details, styles.ContentWidth-6)))
+	}
+	if len(m.Templates) == 0 {
+		dirs := templates.Dirs(m.ProjectKey())
+		items = append(items, lipgloss.NewStyle().Foreground(styles.HintColor).Width(styles.ContentWidth).
+			Render("No templates yet. Add Markdown files to:\n  "+strings.Join(dirs, "\n  ")))
+	}
+
+	hint := lipgloss.NewStyle().
+		Foreground(styles.HintColor).
+		Width(styles.ContentWidth).
+		PaddingTop(1).
+		Render("↑/↓: navigate • Enter: insert into input • Esc: close")
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, lipgloss.JoinVertical(lipgloss.Left, items...), hint)
+}
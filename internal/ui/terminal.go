@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// terminalOutput is stdout with its writes serialized. The renderer writes
+// each frame in one call, so escape sequences sent through it by
+// writeTerminal land between frames, never inside one.
+type terminalOutput struct {
+	*os.File
+	mu sync.Mutex
+}
+
+func (t *terminalOutput) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.File.Write(p)
+}
+
+// writeTerminal returns a command that sends an escape sequence, such as a
+// notification or OSC 52, to the terminal the program draws on. It is nil
+// when there is nothing to send or no terminal.
+func (m *Model) writeTerminal(seq string) tea.Cmd {
+	out := m.Output
+	if out == nil || seq == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		_, _ = io.WriteString(out, seq)
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+package ui
+
+import (
+	"arcane/internal/styles"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LoadThemes reads the custom themes and applies the one named in the config
+func (m *Model) LoadThemes() error {
+	themes, err := styles.LoadThemes()
+	m.Themes = themes
+
+	t := styles.AutoTheme()
+	if name := m.Config.Theme; name != "" && name != "auto" {
+		if found, ok := styles.FindTheme(themes, name); ok {
+			t = found
+		} else {
+			err = errors.Join(err, fmt.Errorf("unknown theme %q", name))
+		}
+	}
+	return errors.Join(err, m.applyTheme(t))
+}
+
+// applyTheme switches every style to t, including the ones copied into the
+// input and spinner, and re-renders all tabs
+func (m *Model) applyTheme(t styles.Theme) error {
+	styles.Apply(t)
+
+	prompt := lipgloss.NewStyle().Foreground(t.Prompt).Bold(true)
+	placeholder := lipgloss.NewStyle().Foreground(t.TextDim)
+	m.TextInput.FocusedStyle.Prompt = prompt
+	m.TextInput.BlurredStyle.Prompt = prompt
+	m.TextInput.FocusedStyle.Placeholder = placeholder
+	m.TextInput.BlurredStyle.Placeholder = placeholder
+	m.Spinner.Style = lipgloss.NewStyle().Foreground(t.Prompt)
+
+	for i := range m.Tabs {
+		for j := range m.Tabs[i].Messages {
+			m.Tabs[i].Messages[j].rendered = ""
+		}
+	}
+	for i := range m.Messages {
+		m.Messages[i].rendered = ""
+	}
+
+	var err error
+	if m.Renderer != nil {
+		err = m.newRenderer(m.Viewport.Width - 4)
+		m.UpdateViewport()
+	}
+	return err
+}
+
+// newRenderer builds the Markdown renderer for the current theme
+func (m *Model) newRenderer(wrap int) error {
+	style, err := styles.CurrentTheme.MarkdownStyle()
+	m.Renderer, _ = glamour.NewTermRenderer(
+		glamour.WithStyles(style),
+		glamour.WithWordWrap(wrap),
+	)
+	return err
+}
+
+// SetTheme switches to a theme by name for this session
+func (m *Model) SetTheme(name string) error {
+	if name == "auto" {
+		return m.applyTheme(styles.AutoTheme())
+	}
+	t, ok := styles.FindTheme(m.Themes, name)
+	if !ok {
+		return fmt.Errorf("unknown theme %q (try %s)", name, strings.Join(m.themeNames(), ", "))
+	}
+	return m.applyTheme(t)
+}
+
+func (m *Model) themeNames() []string {
+	names := make([]string, len(m.Themes))
+	for i, t := range m.Themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// OpenThemeSelector shows the theme picker, re-reading the themes dir so
+// edited files show up. Moving through it previews each theme on the whole
+// screen.
+func (m *Model) OpenThemeSelector() {
+	themes, err := styles.LoadThemes()
+	if err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Theme error: %v", err)))
+	}
+	m.Themes = themes
+	m.ThemeSelectorOpen = true
+	m.ThemeBeforePreview = styles.CurrentTheme
+	m.ThemeSelectedIdx = 0
+	for i, t := range m.Themes {
+		if t.Name == styles.CurrentTheme.Name {
+			m.ThemeSelectedIdx = i
+		}
+	}
+	m.FileSuggestOpen = false
+	m.CommandSuggestOpen = false
+}
+
+// moveThemeSelection moves dir entries, wrapping around, and previews the
+// theme there
+func (m *Model) moveThemeSelection(dir int) {
+	n := len(m.Themes)
+	if n == 0 {
+		return
+	}
+	m.ThemeSelectedIdx = (m.ThemeSelectedIdx + dir + n) % n
+	m.applyTheme(m.Themes[m.ThemeSelectedIdx])
+}
+
+// closeThemeSelector keeps the previewed theme, or goes back to the one in
+// use when the picker opened
+func (m *Model) closeThemeSelector(keep bool) error {
+	m.ThemeSelectorOpen = false
+	if !keep {
+		return m.applyTheme(m.ThemeBeforePreview)
+	}
+	if len(m.Themes) == 0 {
+		return nil
+	}
+	t := m.Themes[m.ThemeSelectedIdx]
+	err := m.applyTheme(t)
+	if t.Name != m.Config.Theme {
+		m.AddNote(styles.InfoStyle(fmt.Sprintf("Theme: %s. Set \"theme\": %q in config.json to keep it.", t.Name, t.Name)))
+		m.UpdateViewport()
+		m.Viewport.GotoBottom()
+	}
+	return err
+}
+
+// RenderThemeSelector shows the theme picker above the input, so the
+// transcript stays visible as the preview
+func (m *Model) RenderThemeSelector() string {
+	if !m.ThemeSelectorOpen {
+		return ""
+	}
+	t := styles.CurrentTheme
+	nameStyle := lipgloss.NewStyle().Foreground(t.TextSecondary).Padding(0, 1)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.OnAccent).Background(t.Accent).Padding(0, 1)
+	tagStyle := lipgloss.NewStyle().Foreground(t.TextMuted)
+
+	width := 0
+	for _, th := range m.Themes {
+		width = max(width, len(th.Name))
+	}
+
+	lines := []string{lipgloss.NewStyle().Foreground(t.TextMuted).Italic(true).
+		Render("  themes (↑↓ preview, Enter keep, Esc cancel)")}
+	for i, th := range m.Themes {
+		var swatch strings.Builder
+		for _, c := range []lipgloss.Color{th.Primary, th.Secondary, th.Accent, th.Highlight, th.Success, th.Error} {
+			swatch.WriteString(lipgloss.NewStyle().Foreground(c).Render("■"))
+		}
+		marker := "  "
+		if th.Name == m.ThemeBeforePreview.Name {
+			marker = "● "
+		}
+		tag := ""
+		if th.Path != "" {
+			tag = " custom"
+		}
+		row := fmt.Sprintf("%s%-*s ", marker, width, th.Name)
+		if i == m.ThemeSelectedIdx {
+			lines = append(lines, selectedStyle.Render(row)+" "+swatch.String()+tagStyle.Render(tag))
+		} else {
+			lines = append(lines, nameStyle.Render(row)+" "+swatch.String()+tagStyle.Render(tag))
+		}
+	}
+
+	popupStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Primary).
+		Background(t.BgElevated).
+		Padding(0, 1)
+	return popupStyle.Render(strings.Join(lines, "\n"))
+}
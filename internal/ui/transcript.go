@@ -0,0 +1,371 @@
+package ui
+
+import (
+	"arcane/internal/models"
+	"arcane/internal/styles"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// RoleNote marks transcript lines produced by the UI itself, such as errors
+// and command output. Their content is stored already styled.
+const RoleNote = "note"
+
+const (
+	codeFoldLines   = 20 // Code blocks longer than this are folded
+	codeFoldPreview = 8  // Lines of a folded code block left visible
+)
+
+// ChatMessage is one entry of the transcript. Prompts and answers keep their
+// raw content and are rendered for the current width when shown.
+type ChatMessage struct {
+	Role      string            // models.RoleUser, RoleAssistant, RoleEvent or RoleNote
+	Content   string            // Markdown for answers, text for prompts and events, styled text for notes
+	Prompt    string            // A prompt as typed, @mentions included; Content is what is shown
+	ToolCalls []models.ToolCall // Tools run while producing an answer
+	Reasoning string            // Thinking shown folded above an answer
+	CreatedAt time.Time
+
+	// Position among sibling branches, shown when there is more than one
+	SiblingIndex int
+	SiblingCount int
+
+	Unfolded bool // Long code blocks, tool outputs and thinking are shown in full
+
+	// Render cache, invalidated by a change of width, focus or folding
+	rendered      string
+	renderedWidth int
+	renderedFocus bool
+
+	// Where the content sits in rendered, between the label, thinking and
+	// tool calls above it and the branch indicator below. Search only looks
+	// at this part.
+	bodyStart, bodyEnd int
+	bodyMargin         int    // Border and padding cells at the start of each body line
+	bodyFolded         bool   // Folding hid part of the content
+	fullBody           string // The content rendered unfolded, when bodyFolded
+}
+
+// NewNote makes a transcript entry from already styled text
+func NewNote(text string) ChatMessage {
+	return ChatMessage{Role: RoleNote, Content: text, CreatedAt: time.Now()}
+}
+
+// AddNote appends styled UI output such as an error to the transcript
+func (m *Model) AddNote(text string) {
+	m.Messages = append(m.Messages, NewNote(text))
+}
+
+// navigable reports whether message jumps stop at msg
+func (msg ChatMessage) navigable() bool {
+	return msg.Role == models.RoleUser || msg.Role == models.RoleAssistant
+}
+
+// Foldable reports whether the message has long code blocks, tool output
+// or thinking that can be folded
+func (msg ChatMessage) Foldable() bool {
+	if msg.Role != models.RoleAssistant {
+		return false
+	}
+	if strings.TrimSpace(msg.Reasoning) != "" {
+		return true
+	}
+	for _, tc := range msg.ToolCalls {
+		if tc.Result != "" {
+			return true
+		}
+	}
+	for _, b := range CodeBlocks(msg.Content) {
+		if b.Lines() > codeFoldLines {
+			return true
+		}
+	}
+	return false
+}
+
+// chatMessageFromDB converts a stored message for display
+func chatMessageFromDB(msg models.DBMessage) ChatMessage {
+	chat := ChatMessage{
+		Role:         msg.Role,
+		Content:      msg.Content,
+		ToolCalls:    msg.ToolCalls,
+		Reasoning:    msg.Reasoning,
+		CreatedAt:    time.Unix(msg.CreatedAtUnix, 0),
+		SiblingIndex: msg.SiblingIndex,
+		SiblingCount: msg.SiblingCount,
+	}
+	if msg.Role == models.RoleUser {
+		chat.Prompt = msg.Content
+	}
+	return chat
+}
+
+// renderMessage renders message i, reusing the cached result when the width,
+// focus and folding are unchanged
+func (m *Model) renderMessage(i int) string {
+	msg := &m.Messages[i]
+	width := m.Viewport.Width
+	focused := i == m.FocusedMessage
+	if msg.rendered != "" && msg.renderedWidth == width && msg.renderedFocus == focused {
+		return msg.rendered
+	}
+
+	var head, body, label string
+	msg.bodyFolded, msg.fullBody, msg.bodyMargin = false, "", 0
+	switch msg.Role {
+	case models.RoleUser:
+		label = styles.UserLabelStyle.Render("YOU")
+		head = label
+		if i == 0 {
+			head = "\n" + label
+		}
+		body = styles.UserMsgStyle.Width(width - 4).Render(msg.Content)
+		msg.bodyMargin = styles.UserMsgStyle.GetBorderLeftSize() + styles.UserMsgStyle.GetPaddingLeft()
+	case models.RoleAssistant:
+		label = styles.AiLabelStyle.Render("ARCANE")
+		above := []string{label}
+		if thinking := m.formatReasoning(msg.Reasoning, msg.Unfolded, width); thinking != "" {
+			above = append(above, thinking)
+		}
+		if len(msg.ToolCalls) > 0 {
+			above = append(above, m.formatToolCalls(msg.ToolCalls, msg.Unfolded, width))
+		}
+		head = strings.Join(above, "\n")
+		content := msg.Content
+		if !msg.Unfolded {
+			content = foldCodeBlocks(content, m.KeyHelp("toggle_fold"))
+			msg.bodyFolded = content != msg.Content
+		}
+		body = m.formatAnswer(content)
+		msg.bodyMargin = styles.AiMsgStyle.GetBorderLeftSize() + styles.AiMsgStyle.GetPaddingLeft()
+	case models.RoleEvent:
+		body = FormatEvent(msg.Content)
+	default:
+		head = msg.Content
+	}
+	if focused && label != "" {
+		head = strings.Replace(head, label, label+styles.FocusMarkerStyle.Render("◆"), 1)
+	}
+	out := head
+	if head != "" && body != "" {
+		out += "\n"
+	}
+	msg.bodyStart = len(out)
+	out += body
+	msg.bodyEnd = len(out)
+	if msg.SiblingCount > 1 {
+		out += "\n" + FormatBranchIndicator(msg.SiblingIndex, msg.SiblingCount, m.KeyHelp("prev_branch", "next_branch"))
+	}
+
+	msg.rendered, msg.renderedWidth, msg.renderedFocus = out, width, focused
+	return out
+}
+
+// formatAnswer renders the Markdown of an answer
+func (m *Model) formatAnswer(content string) string {
+	if m.Renderer != nil {
+		r, _ := m.Renderer.Render(content)
+		content = strings.TrimSpace(r)
+	}
+	return styles.AiMsgStyle.Render(content)
+}
+
+// searchBody returns the content of message i as rendered with nothing
+// folded, which is what search counts matches in
+func (m *Model) searchBody(i int) string {
+	out := m.renderMessage(i)
+	msg := &m.Messages[i]
+	if !msg.bodyFolded {
+		return out[msg.bodyStart:msg.bodyEnd]
+	}
+	if msg.fullBody == "" {
+		msg.fullBody = m.formatAnswer(msg.Content)
+	}
+	return msg.fullBody
+}
+
+// formatToolCalls lists the tools run for an answer. Their output is folded
+// to a line count unless unfolded is set.
+func (m *Model) formatToolCalls(calls []models.ToolCall, unfolded bool, width int) string {
+	var lines []string
+	for _, tc := range calls {
+		icon := styles.ToolIconStyle.Render("→")
+		name := styles.ToolNameStyle.Render(tc.Summary)
+		line := fmt.Sprintf("%s %s", icon, name)
+		result := strings.TrimRight(tc.Result, "\n")
+		if result == "" {
+			lines = append(lines, styles.ToolActionStyle.Render(line))
+			continue
+		}
+		n := strings.Count(result, "\n") + 1
+		if !unfolded {
+			hint := fmt.Sprintf("▸ %d lines", n)
+			if n == 1 {
+				hint = "▸ 1 line"
+			}
+			lines = append(lines, styles.ToolActionStyle.Render(line+" "+styles.ToolDetailStyle.Render(hint)))
+			continue
+		}
+		lines = append(lines, styles.ToolActionStyle.Render(line+" "+styles.ToolDetailStyle.Render("▾")))
+		for _, l := range strings.Split(result, "\n") {
+			l = TruncateRunes(strings.ReplaceAll(l, "\t", "    "), max(10, width-8))
+			lines = append(lines, styles.ToolActionStyle.Render("  "+styles.ToolDetailStyle.Render("│ "+l)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// foldCodeBlocks cuts fenced code blocks longer than codeFoldLines down to a
+// preview followed by a note of how many lines are hidden
+func foldCodeBlocks(markdown, foldKey string) string {
+	source := []byte(markdown)
+	doc := markdownParser.Parse(text.NewReader(source))
+
+	var out strings.Builder
+	pos := 0
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		fenced, ok := n.(*ast.FencedCodeBlock)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		lines := fenced.Lines()
+		if lines.Len() <= codeFoldLines {
+			return ast.WalkSkipChildren, nil
+		}
+		cut := lines.At(codeFoldPreview).Start
+		end := lines.At(lines.Len() - 1).Stop
+		note := fmt.Sprintf("⋯ %d more lines", lines.Len()-codeFoldPreview)
+		if foldKey != "" {
+			note += fmt.Sprintf(" (%s to unfold)", foldKey)
+		}
+		out.Write(source[pos:cut])
+		out.WriteString(note + "\n")
+		pos = end
+		return ast.WalkSkipChildren, nil
+	})
+	if pos == 0 {
+		return markdown
+	}
+	out.Write(source[pos:])
+	return out.String()
+}
+
+// transcript renders all messages, recording the first line of each in
+// MessageLines so jumps can scroll to them. While searching, matches in the
+// content of messages are highlighted and ChatSearchLine is set to the line
+// of the current hit.
+func (m *Model) transcript() string {
+	searching := m.ChatSearchOpen && m.ChatSearchQuery != "" && m.inFront()
+	var current chatSearchHit
+	if searching {
+		// Messages may have changed since the search ran
+		m.ChatSearchHits = m.chatSearchHits()
+		m.ChatSearchIdx = min(m.ChatSearchIdx, max(len(m.ChatSearchHits)-1, 0))
+		current = chatSearchHit{Msg: -1}
+		if len(m.ChatSearchHits) > 0 {
+			current = m.ChatSearchHits[m.ChatSearchIdx]
+		}
+	}
+
+	var b strings.Builder
+	m.MessageLines = m.MessageLines[:0]
+	line := 0
+	for i := range m.Messages {
+		if i > 0 {
+			b.WriteString("\n\n")
+			line += 2
+		}
+		m.MessageLines = append(m.MessageLines, line)
+		out := m.renderMessage(i)
+		if msg := m.Messages[i]; searching && msg.bodyEnd > msg.bodyStart {
+			n := -1
+			if i == current.Msg {
+				n = current.N
+			}
+			body, hitLines := highlightMatches(out[msg.bodyStart:msg.bodyEnd], m.ChatSearchQuery, msg.bodyMargin, n)
+			if n >= 0 {
+				m.ChatSearchLine = line + strings.Count(out[:msg.bodyStart], "\n")
+				if n < len(hitLines) {
+					m.ChatSearchLine += hitLines[n]
+				}
+			}
+			out = out[:msg.bodyStart] + body + out[msg.bodyEnd:]
+		}
+		b.WriteString(out)
+		line += strings.Count(out, "\n")
+	}
+	return b.String()
+}
+
+// JumpMessage focuses the previous (dir < 0) or next prompt or answer and
+// scrolls it to the top of the chat. Without a focused message, the first
+// jump back lands on the newest one and a jump forward on the first one
+// below the top of the chat.
+func (m *Model) JumpMessage(dir int) {
+	start := m.FocusedMessage
+	if start < 0 || start >= len(m.Messages) {
+		start = len(m.Messages)
+		if dir > 0 {
+			start = -1
+			for i, l := range m.MessageLines {
+				if l >= m.Viewport.YOffset {
+					start = i - 1
+					break
+				}
+			}
+		}
+	}
+	for i := start + dir; i >= 0 && i < len(m.Messages); i += dir {
+		if m.Messages[i].navigable() {
+			m.FocusedMessage = i
+			break
+		}
+	}
+	if m.FocusedMessage < 0 {
+		return
+	}
+	m.UpdateViewport()
+	if m.FocusedMessage < len(m.MessageLines) {
+		m.Viewport.SetYOffset(m.MessageLines[m.FocusedMessage])
+	}
+}
+
+// ClearFocus ends message navigation
+func (m *Model) ClearFocus() {
+	m.FocusedMessage = -1
+	m.UpdateViewport()
+}
+
+// ToggleFold folds or unfolds the focused message, or the newest foldable
+// one when no message is focused
+func (m *Model) ToggleFold() error {
+	idx := m.FocusedMessage
+	if idx < 0 || idx >= len(m.Messages) {
+		idx = -1
+		for i := len(m.Messages) - 1; i >= 0; i-- {
+			if m.Messages[i].Foldable() {
+				idx = i
+				break
+			}
+		}
+	}
+	if idx < 0 || !m.Messages[idx].Foldable() {
+		return fmt.Errorf("nothing to fold here")
+	}
+	msg := &m.Messages[idx]
+	msg.Unfolded = !msg.Unfolded
+	msg.rendered = ""
+
+	// Keep the toggled message in place on screen
+	offset := m.Viewport.YOffset
+	m.UpdateViewport()
+	if idx < len(m.MessageLines) && m.MessageLines[idx] < offset {
+		offset = m.MessageLines[idx]
+	}
+	m.Viewport.SetYOffset(offset)
+	return nil
+}
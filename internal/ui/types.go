@@ -1,10 +1,17 @@
 package ui
 
 import (
+	"arcane/internal/config"
+	"arcane/internal/instructions"
 	"arcane/internal/models"
+	"arcane/internal/retry"
+	"arcane/internal/styles"
+	"arcane/internal/templates"
 	"context"
 	"database/sql"
+	"io"
 	"regexp"
+	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -70,10 +77,17 @@ type ErrMsg error
 type StreamChunkMsg struct{ Delta string }
 type CancelledMsg struct{}
 
+// ReasoningChunkMsg carries thinking the model sent before its answer
+type ReasoningChunkMsg struct{ Delta string }
+
+// RetryMsg reports that a failed API call will be retried after a delay
+type RetryMsg struct{ Attempt retry.Attempt }
+
 type ToolExecRecord struct {
-	Name   string
-	Args   string
-	Result string
+	Name    string
+	Args    string
+	Result  string
+	Summary string
 }
 
 var InlineToolCallRE = regexp.MustCompile(`^\s*([a-zA-Z][a-zA-Z0-9_]*)\s*(\{.*\})\s*$`)
@@ -90,6 +104,9 @@ type ResponseMsg struct {
 	CompletionTokens int64
 	History          []openai.ChatCompletionMessageParamUnion
 	ContextTokens    int
+	ModelID          string            // Model that answered (may be a fallback)
+	ToolCalls        []models.ToolCall // Tools run during the turn
+	Reasoning        string            // Thinking sent before the answer, if any
 }
 
 type ToolCallMsg struct {
@@ -103,21 +120,74 @@ type ToolResultMsg struct {
 	Summary string // Brief summary of the action taken
 }
 
+// Session is the state of one conversation. Each tab has its own, so a
+// request can keep streaming in a background tab.
+type Session struct {
+	ID                 int // Tags the messages of this session's requests
+	Messages           []ChatMessage
+	CurrentChatID      int64
+	History            []openai.ChatCompletionMessageParamUnion
+	Loading            bool
+	InputTokens        int64
+	OutputTokens       int64
+	CurrentModel       models.AIModel
+	SelectedModelIndex int
+	ExecutingTool      string
+	ToolArguments      string
+	ToolActions        []models.ToolAction // Completed tool actions for current response
+	ContextTokens      int
+	AppMode            models.AppMode
+	SystemPrompt       string // Mode or persona prompt stored with the chat; empty until first resolved
+	Persona            string // Active persona name, empty for the built-in prompts
+
+	// Message navigation
+	FocusedMessage int   // Index into Messages of the jumped-to message, -1 for none
+	MessageLines   []int // First transcript line of each message
+
+	// Streaming
+	StreamingContent   string             // Accumulated streaming response being built
+	StreamingReasoning string             // Thinking received so far for the response
+	CancelFn           context.CancelFunc // Cancel function for the in-progress request
+	RequestStart       time.Time          // When the in-progress request was sent
+
+	// Input given while a response is generating
+	Queued        []string    // Sent in order once the turn ends
+	Steering      *steerQueue // Read by the running agent turn between tool iterations
+	PendingSteers []string    // Steering messages the agent has not taken yet
+
+	// Branching
+	Path             []models.DBMessage // Active branch of the current chat, as stored
+	LeafMessageID    int64              // Parent for the next persisted message
+	EditingMessageID int64              // User message being edited for resend (0 when not editing)
+
+	// Retry status for the in-progress request
+	RetryStatus string    // Why we are retrying, e.g. "429 Too Many Requests"
+	RetryModel  string    // Model the next attempt will use
+	RetryUntil  time.Time // When the next attempt starts
+
+	// Kept while the tab is in the background
+	Unread       bool   // An answer or error arrived in the background
+	ScrollOffset int    // Viewport position
+	Draft        string // Unsent input
+}
+
 type Model struct {
+	Session // The conversation in the active tab
+
+	// Tabs, each with its own conversation. The entry for ActiveTab is stale
+	// while it is active; the live state is the embedded Session.
+	Tabs          []Session
+	ActiveTab     int
+	nextSessionID int
+
 	Viewport           viewport.Model
-	Messages           []string
 	TextInput          textarea.Model
 	Spinner            spinner.Model
 	Client             openai.Client
 	DB                 *sql.DB
 	DBErr              error
-	CurrentChatID      int64
-	History            []openai.ChatCompletionMessageParamUnion
 	Renderer           *glamour.TermRenderer
 	Err                error
-	Loading            bool
-	InputTokens        int64
-	OutputTokens       int64
 	WindowWidth        int
 	WindowHeight       int
 	HistoryOpen        bool
@@ -126,17 +196,64 @@ type Model struct {
 	HistoryChats       []models.ChatListItem
 	HistoryErr         error
 	HistoryPage        int
+	HistoryAllProjects bool // List chats from every project instead of the current one
 	ModelSelectorOpen  bool
 	ShortcutsOpen      bool
-	CurrentModel       models.AIModel
-	SelectedModelIndex int
 	ModelViewport      viewport.Model
-	ExecutingTool      string
-	ToolArguments      string
-	ToolActions        []models.ToolAction // Completed tool actions for current response
 	Program            *tea.Program
-	ContextTokens      int
-	AppMode            models.AppMode
+	Output             io.Writer // The terminal, shared with Program's renderer
+
+	// Persona picker
+	PersonaSelectorOpen bool
+	PersonaSelectedIdx  int // 0 is the default persona, then Config.Personas in order
+
+	// Theme picker
+	Themes             []styles.Theme // Built-in themes, then custom ones
+	ThemeSelectorOpen  bool
+	ThemeSelectedIdx   int
+	ThemeBeforePreview styles.Theme // Restored when the picker is cancelled
+
+	// Search in the current conversation
+	ChatSearchOpen    bool
+	ChatSearchEditing bool // Typing the query; otherwise n/N step through hits
+	ChatSearchQuery   string
+	ChatSearchHits    []chatSearchHit
+	ChatSearchIdx     int // Current hit
+	ChatSearchLine    int // Transcript line of the current hit
+
+	// Message selection for copying
+	CopySelectorOpen bool
+	CopySelectedIdx  int // Index into the messages copyableMessages returns
+
+	// Prompt templates and their picker
+	Templates            []templates.Template
+	TemplatesErr         string // Last load error, reported once
+	TemplateSelectorOpen bool
+	TemplateSelectedIdx  int
+
+	// Command palette (Ctrl+P)
+	PaletteOpen  bool
+	PaletteQuery string
+	PaletteItems []Command
+	PaletteIdx   int
+
+	// Recall of past inputs with Up/Down and Ctrl+R
+	InputHistory       []string // Newest first
+	InputHistoryIdx    int      // Entry shown in the input, -1 when not browsing
+	InputHistoryDraft  string   // Input typed before browsing started
+	InputHistoryGlobal bool     // Recall inputs from every project
+	InputSearchOpen    bool
+	InputSearchQuery   string
+	InputSearchIdx     int
+	InputSearchFailed  bool
+
+	// Global keybindings, from the defaults and config
+	Keys KeyMap
+
+	// Slash command autocomplete
+	CommandSuggestOpen bool
+	CommandSuggestions []Command
+	CommandSuggestIdx  int
 
 	// File mention autocomplete
 	FileSuggestOpen   bool
@@ -146,13 +263,21 @@ type Model struct {
 	AttachedFiles     []string // Files attached via @mention for current message
 	PendingFiles      []string // Files detected in current input (for display)
 
-	// Working directory
+	// Working directory and the git repository containing it, if any
 	WorkingDir string
+	GitRoot    string
+
+	// Instruction files (ARCANE.md, AGENTS.md) merged into the system prompt
+	Instructions      []instructions.File
+	InstructionsStamp string
 
 	// Mouse interaction
 	MouseHoverArt bool
 
-	// Streaming
-	StreamingContent string             // Accumulated streaming response being built
-	CancelFn         context.CancelFunc // Cancel function for the in-progress request
+	// Reasoning effort set with /effort by model ID, taking precedence over
+	// Config.ReasoningEffort. An empty value uses the provider's default.
+	ReasoningEffort map[string]string
+
+	// User configuration
+	Config config.Config
 }
@@ -2,21 +2,23 @@ package ui
 
 import (
 	"arcane/internal/db"
+	"arcane/internal/instructions"
 	"arcane/internal/models"
+	"arcane/internal/project"
+	"arcane/internal/retry"
 	"arcane/internal/styles"
 	"arcane/internal/tools"
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/glamour"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/openai/openai-go/v3"
 )
 
@@ -57,6 +59,9 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		// Let mouse events fall through to viewports for scrolling support
 
+	case TabMsg:
+		return m, m.routeTabMsg(msg)
+
 	case spinner.TickMsg:
 		m.Spinner, spCmd = m.Spinner.Update(msg)
 		if m.Loading {
@@ -66,10 +71,16 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		if m.HistoryOpen {
-			switch msg.String() {
-			case "ctrl+c":
+			if key.Matches(msg, m.Keys.Quit) {
 				return m, tea.Quit
-			case "esc", "ctrl+h":
+			}
+			if key.Matches(msg, m.Keys.History) {
+				m.HistoryOpen = false
+				m.HistoryErr = nil
+				return m, nil
+			}
+			switch msg.String() {
+			case "esc":
 				m.HistoryOpen = false
 				m.HistoryErr = nil
 				return m, nil
@@ -96,13 +107,33 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 				chat := m.HistoryChats[m.HistorySelectedIdx]
-				if err := m.LoadChatFromDB(chat.ID, chat.ModelID); err != nil {
+				if err := m.LoadChatFromDB(chat); err != nil {
 					m.HistoryErr = err
 					return m, nil
 				}
 				m.HistoryOpen = false
 				m.HistoryErr = nil
 				return m, nil
+			case "f":
+				if len(m.HistoryChats) == 0 {
+					return m, nil
+				}
+				chat := m.HistoryChats[m.HistorySelectedIdx]
+				if _, err := m.ForkChat(chat.ID, 0); err != nil {
+					m.HistoryErr = err
+					return m, nil
+				}
+				m.HistoryOpen = false
+				m.HistoryErr = nil
+				m.AddNote(styles.InfoStyle(fmt.Sprintf("Forked chat #%d into #%d", chat.ID, m.CurrentChatID)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+				return m, nil
+			case "p":
+				m.HistoryAllProjects = !m.HistoryAllProjects
+				m.HistoryPage = 0
+				m.RefreshHistoryFromDB()
+				return m, nil
 			case "left", "h":
 				if m.HistoryPage > 0 {
 					m.HistoryPage--
@@ -121,13 +152,15 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if m.ModelSelectorOpen {
-			switch msg.String() {
-			case "ctrl+c":
+			if key.Matches(msg, m.Keys.Quit) {
 				return m, tea.Quit
-			case "esc":
+			}
+			if key.Matches(msg, m.Keys.SelectModel) {
 				m.ModelSelectorOpen = false
 				return m, nil
-			case "ctrl+b":
+			}
+			switch msg.String() {
+			case "esc":
 				m.ModelSelectorOpen = false
 				return m, nil
 			case "up", "k":
@@ -154,24 +187,200 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		if m.ShortcutsOpen {
+		if m.PersonaSelectorOpen {
+			if key.Matches(msg, m.Keys.Quit) {
+				return m, tea.Quit
+			}
+			switch msg.String() {
+			case "esc":
+				m.PersonaSelectorOpen = false
+				return m, nil
+			case "up", "k":
+				m.PersonaSelectedIdx--
+				if m.PersonaSelectedIdx < 0 {
+					m.PersonaSelectedIdx = len(m.Config.Personas)
+				}
+				return m, nil
+			case "down", "j":
+				m.PersonaSelectedIdx++
+				if m.PersonaSelectedIdx > len(m.Config.Personas) {
+					m.PersonaSelectedIdx = 0
+				}
+				return m, nil
+			case "enter":
+				name := ""
+				if m.PersonaSelectedIdx > 0 {
+					name = m.Config.Personas[m.PersonaSelectedIdx-1].Name
+				}
+				m.PersonaSelectorOpen = false
+				if err := m.SetPersona(name); err != nil {
+					m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Persona: %v", err)))
+					m.UpdateViewport()
+					m.Viewport.GotoBottom()
+				}
+				return m, nil
+			}
+			return m, nil
+		}
+
+		if m.ThemeSelectorOpen {
+			if key.Matches(msg, m.Keys.Quit) {
+				return m, tea.Quit
+			}
+			var err error
+			switch msg.String() {
+			case "esc":
+				err = m.closeThemeSelector(false)
+			case "enter":
+				err = m.closeThemeSelector(true)
+			case "up", "k", "shift+tab":
+				m.moveThemeSelection(-1)
+			case "down", "j", "tab":
+				m.moveThemeSelection(1)
+			}
+			if err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Theme: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
+			return m, nil
+		}
+
+		if m.PaletteOpen {
+			return m, m.handlePaletteKey(msg)
+		}
+
+		if m.CopySelectorOpen {
+			if key.Matches(msg, m.Keys.Quit) {
+				return m, tea.Quit
+			}
+			msgs, _ := m.copyableMessages()
+			n := len(msgs)
 			switch msg.String() {
-			case "ctrl+c":
+			case "esc":
+				m.CopySelectorOpen = false
+			case "up", "k":
+				if n > 0 {
+					m.CopySelectedIdx = (m.CopySelectedIdx - 1 + n) % n
+				}
+			case "down", "j":
+				if n > 0 {
+					m.CopySelectedIdx = (m.CopySelectedIdx + 1) % n
+				}
+			case "enter", "y", "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				block := 0
+				if r := msg.String(); r >= "1" && r <= "9" {
+					block = int(r[0] - '0')
+				}
+				cmd, err := m.copySelected(block)
+				if err != nil {
+					m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Copy: %v", err)))
+				}
+				m.CopySelectorOpen = false
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+				return m, cmd
+			}
+			return m, nil
+		}
+
+		if m.TemplateSelectorOpen {
+			if key.Matches(msg, m.Keys.Quit) {
+				return m, tea.Quit
+			}
+			switch msg.String() {
+			case "esc":
+				m.TemplateSelectorOpen = false
+				return m, nil
+			case "up", "k":
+				if len(m.Templates) > 0 {
+					m.TemplateSelectedIdx = (m.TemplateSelectedIdx - 1 + len(m.Templates)) % len(m.Templates)
+				}
+				return m, nil
+			case "down", "j":
+				if len(m.Templates) > 0 {
+					m.TemplateSelectedIdx = (m.TemplateSelectedIdx + 1) % len(m.Templates)
+				}
+				return m, nil
+			case "enter":
+				m.TemplateSelectorOpen = false
+				if m.TemplateSelectedIdx < len(m.Templates) {
+					if err := m.ExpandTemplate(m.Templates[m.TemplateSelectedIdx], ""); err != nil {
+						m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Template: %v", err)))
+						m.UpdateViewport()
+						m.Viewport.GotoBottom()
+					}
+				}
+				return m, nil
+			}
+			return m, nil
+		}
+
+		if m.ShortcutsOpen {
+			if key.Matches(msg, m.Keys.Quit) {
 				return m, tea.Quit
-			case "esc", "enter", "?", "ctrl+s":
+			}
+			switch msg.String() {
+			case "esc", "enter", "?":
 				m.ShortcutsOpen = false
 				return m, nil
 			}
+			if key.Matches(msg, m.Keys.Shortcuts) {
+				m.ShortcutsOpen = false
+			}
 			return m, nil
 		}
 
-		if isNewlineShortcut(msg) {
+		if m.InputSearchOpen {
+			return m, m.handleInputSearchKey(msg)
+		}
+
+		if m.ChatSearchOpen {
+			if cmd, ok := m.handleChatSearchKey(msg); ok {
+				return m, cmd
+			}
+		}
+
+		if key.Matches(msg, m.Keys.Newline) {
 			m.TextInput.InsertString("\n")
 			m.FileSuggestOpen = false
+			m.CommandSuggestOpen = false
 			m.updateInputLayout()
 			return m, nil
 		}
 
+		// Slash command popup handling
+		if m.CommandSuggestOpen {
+			switch msg.String() {
+			case "esc":
+				m.CommandSuggestOpen = false
+				return m, nil
+			case "up", "ctrl+p":
+				m.CommandSuggestIdx--
+				if m.CommandSuggestIdx < 0 {
+					m.CommandSuggestIdx = len(m.CommandSuggestions) - 1
+				}
+				return m, nil
+			case "down", "ctrl+n":
+				m.CommandSuggestIdx++
+				if m.CommandSuggestIdx >= len(m.CommandSuggestions) {
+					m.CommandSuggestIdx = 0
+				}
+				return m, nil
+			case "tab":
+				m.completeCommand()
+				return m, nil
+			case "enter":
+				// Run a complete command as typed; otherwise complete the
+				// selection and run it only if it takes no arguments
+				name, _, _ := ParseCommand(m.TextInput.Value())
+				if _, found := m.FindCommand(name); !found && !m.completeCommand() {
+					return m, nil
+				}
+				m.CommandSuggestOpen = false
+			}
+		}
+
 		// File suggestion popup handling
 		if m.FileSuggestOpen {
 			switch msg.String() {
@@ -214,59 +423,175 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-		switch msg.Type {
-		case tea.KeyCtrlC:
-			if m.Loading && m.CancelFn != nil {
-				m.CancelFn()
-			}
-			return m, tea.Quit
+		if m.recallInput(msg) {
+			return m, nil
+		}
 
-		case tea.KeyEsc:
-			if m.FileSuggestOpen {
-				m.FileSuggestOpen = false
-				return m, nil
-			}
+		switch {
+		case key.Matches(msg, m.Keys.Quit):
 			if m.Loading && m.CancelFn != nil {
 				m.CancelFn()
-				return m, nil
 			}
 			return m, tea.Quit
 
-		case tea.KeyCtrlN:
+		case key.Matches(msg, m.Keys.NewChat):
 			m.ResetSession()
 			return m, nil
 
-		case tea.KeyCtrlA:
+		case key.Matches(msg, m.Keys.ToggleMode):
 			// Toggle between Chat and Agent mode
-			if m.AppMode == models.ModeChat {
-				m.AppMode = models.ModeAgent
-			} else {
-				m.AppMode = models.ModeChat
+			mode := models.ModeAgent
+			if m.AppMode == models.ModeAgent {
+				mode = models.ModeChat
+			}
+			if err := m.SetMode(mode); err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
 			}
 			return m, nil
 
-		case tea.KeyCtrlB:
-			m.ModelSelectorOpen = true
-			m.HistoryOpen = false
-			m.ShortcutsOpen = false
-			m.UpdateModelSelectorContent() // Initial render
-			m.SyncModelViewportScroll()    // Initial scroll sync
+		case key.Matches(msg, m.Keys.Palette):
+			m.OpenPalette()
 			return m, nil
 
-		case tea.KeyCtrlS: // Using Ctrl+S for shortcuts
+		case key.Matches(msg, m.Keys.SelectModel):
+			m.OpenModelSelector()
+			return m, nil
+
+		case key.Matches(msg, m.Keys.Shortcuts):
 			m.ShortcutsOpen = true
 			m.ModelSelectorOpen = false
 			m.HistoryOpen = false
 			return m, nil
 
-		case tea.KeyCtrlH:
-			m.ModelSelectorOpen = false
-			m.HistoryOpen = true
-			m.ShortcutsOpen = false
-			m.HistoryPage = 0
-			m.RefreshHistoryFromDB()
+		case key.Matches(msg, m.Keys.History):
+			m.OpenHistory()
+			return m, nil
+
+		case key.Matches(msg, m.Keys.SearchInput):
+			m.OpenInputSearch()
+			return m, nil
+
+		case key.Matches(msg, m.Keys.NewTab):
+			m.NewTab()
+			return m, nil
+
+		case key.Matches(msg, m.Keys.CloseTab):
+			if err := m.CloseTab(); err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Tabs: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
+			return m, nil
+
+		case key.Matches(msg, m.Keys.NextTab):
+			m.SwitchTab(1)
+			return m, nil
+
+		case key.Matches(msg, m.Keys.PrevTab):
+			m.SwitchTab(-1)
+			return m, nil
+
+		case key.Matches(msg, m.Keys.EditQueued):
+			if err := m.EditQueued(); err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Queue: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
+			return m, nil
+
+		case key.Matches(msg, m.Keys.Find):
+			m.OpenChatSearch("")
+			return m, nil
+
+		case key.Matches(msg, m.Keys.CopyMode):
+			if err := m.OpenCopySelector(); err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Copy: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
+			return m, nil
+
+		case key.Matches(msg, m.Keys.Editor):
+			cmd, err := m.OpenEditor(m.TextInput.Value())
+			if err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Editor: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.Keys.ScrollUp):
+			m.Viewport.LineUp(3)
+			return m, nil
+
+		case key.Matches(msg, m.Keys.ScrollDown):
+			m.Viewport.LineDown(3)
+			return m, nil
+
+		case key.Matches(msg, m.Keys.PrevMessage):
+			m.JumpMessage(-1)
+			return m, nil
+
+		case key.Matches(msg, m.Keys.NextMessage):
+			m.JumpMessage(1)
+			return m, nil
+
+		case key.Matches(msg, m.Keys.ToggleFold):
+			if err := m.ToggleFold(); err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Fold: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
 			return m, nil
 
+		case key.Matches(msg, m.Keys.EditPrevious):
+			m.EditPreviousMessage()
+			return m, nil
+
+		case key.Matches(msg, m.Keys.Regenerate):
+			cmd, err := m.Regenerate("")
+			if err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Regenerate: %v", err)))
+				m.UpdateViewport()
+				m.Viewport.GotoBottom()
+			}
+			return m, cmd
+
+		case key.Matches(msg, m.Keys.PrevBranch, m.Keys.NextBranch):
+			dir := 1
+			if key.Matches(msg, m.Keys.PrevBranch) {
+				dir = -1
+			}
+			if err := m.SwitchBranch(dir); err != nil {
+				m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+				m.UpdateViewport()
+			}
+			m.Viewport.GotoBottom()
+			return m, nil
+		}
+
+		switch msg.Type {
+		case tea.KeyEsc:
+			if m.FileSuggestOpen {
+				m.FileSuggestOpen = false
+				return m, nil
+			}
+			if m.Loading && m.CancelFn != nil {
+				m.CancelFn()
+				return m, nil
+			}
+			if m.EditingMessageID != 0 {
+				m.cancelEdit()
+				return m, nil
+			}
+			if m.FocusedMessage >= 0 {
+				m.ClearFocus()
+				return m, nil
+			}
+			return m, tea.Quit
+
 		case tea.KeyEnter:
 			// If file suggestions are open, handle selection instead
 			if m.FileSuggestOpen && len(m.FileSuggestions) > 0 {
@@ -285,176 +610,54 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
-			if m.Loading {
-				return m, nil
-			}
 			input := m.TextInput.Value()
 			if input == "" {
-				return m, nil
+				// Send a follow-up left queued by a failed or cancelled turn
+				return m, m.sendQueued()
 			}
+			m.recordInput(input)
 
-			if input == "/clear" || input == "/reset" {
-				m.ResetSession()
-				return m, nil
-			}
-
-			// Extract file mentions and build context
-			cleanInput, files := ExtractFileMentions(input)
-			m.AttachedFiles = files
-
-			// Display message shows clean input but indicates attached files
-			displayInput := cleanInput
-			if len(files) > 0 {
-				fileNames := make([]string, len(files))
-				for i, f := range files {
-					fileNames[i] = filepath.Base(f)
-				}
-				displayInput = fmt.Sprintf("%s\n📎 %s", cleanInput, strings.Join(fileNames, ", "))
-			}
-
-			m.Messages = append(m.Messages, FormatUserMessage(displayInput, m.Viewport.Width, len(m.Messages) == 0))
-			if err := m.PersistUserMessage(input); err != nil {
-				m.Messages = append(m.Messages, styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+			if _, _, ok := ParseCommand(input); ok {
+				return m, m.RunCommand(input)
 			}
 			m.TextInput.Reset()
 			m.updateInputLayout()
 			m.FileSuggestOpen = false
-			m.Loading = true
-			m.StreamingContent = ""
-			m.UpdateViewport()
-			m.Viewport.GotoBottom()
-
-			ctx, cancel := context.WithCancel(context.Background())
-			m.CancelFn = cancel
-			return m, tea.Batch(m.SendMessage(ctx, input), m.Spinner.Tick)
+			if m.Loading {
+				m.QueueInput(input)
+				return m, nil
+			}
+			return m, m.sendInput(input)
 		}
 
-		switch msg.String() {
-		case "alt+up":
-			m.Viewport.LineUp(3)
-			return m, nil
-		case "alt+down":
-			m.Viewport.LineDown(3)
+	case EditorFinishedMsg:
+		if msg.Err != nil {
+			m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Editor: %v", msg.Err)))
+			m.UpdateViewport()
+			m.Viewport.GotoBottom()
 			return m, nil
 		}
-
-	case StreamChunkMsg:
-		m.StreamingContent += msg.Delta
-		m.UpdateViewport()
-		m.Viewport.GotoBottom()
-		return m, nil
-
-	case CancelledMsg:
-		m.Loading = false
-		m.StreamingContent = ""
-		m.ExecutingTool = ""
-		m.ToolArguments = ""
-		m.ToolActions = nil
-		m.CancelFn = nil
-		m.UpdateViewport()
-		return m, nil
-
-	case ToolCallMsg:
-		m.ExecutingTool = msg.Name
-		m.ToolArguments = msg.Arguments
-		m.UpdateViewport()
-		m.Viewport.GotoBottom()
+		m.TextInput.SetValue(msg.Content)
+		m.updateInputLayout()
+		_, m.PendingFiles = ExtractFileMentions(msg.Content)
 		return m, nil
 
-	case ToolResultMsg:
-		m.ExecutingTool = ""
-		m.ToolArguments = ""
-		// Store the completed tool action for display
-		m.ToolActions = append(m.ToolActions, models.ToolAction{
-			Name:    msg.Name,
-			Summary: msg.Summary,
-		})
+	case StreamChunkMsg, ReasoningChunkMsg, RetryMsg, CancelledMsg, SteerMsg, ToolCallMsg, ToolResultMsg, ResponseMsg, ErrMsg:
+		cmd := m.applyRequestMsg(msg)
 		m.UpdateViewport()
-		m.Viewport.GotoBottom()
-		return m, nil
-
-	case ResponseMsg:
-		m.Loading = false
-		m.StreamingContent = ""
-		if m.CancelFn != nil {
-			m.CancelFn()
-			m.CancelFn = nil
-		}
-		m.InputTokens += msg.PromptTokens
-		m.OutputTokens += msg.CompletionTokens
-		m.History = msg.History
-		m.ContextTokens = msg.ContextTokens
-		displayContent := msg.Content
-		if m.Renderer != nil {
-			rendered, _ := m.Renderer.Render(msg.Content)
-			displayContent = strings.TrimSpace(rendered)
-		}
-		// If there were tool actions, prepend them to the message
-		if len(m.ToolActions) > 0 {
-			toolDisplay := FormatToolActions(m.ToolActions)
-			m.Messages = append(m.Messages, FormatAIMessageWithTools(toolDisplay, displayContent))
-		} else {
-			m.Messages = append(m.Messages, FormatAIMessage(displayContent))
-		}
-		m.ToolActions = nil // Clear for next response
-		if err := m.PersistAssistantMessage(msg.Content); err != nil {
-			m.Messages = append(m.Messages, styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+		if _, cancelled := msg.(CancelledMsg); !cancelled {
+			m.Viewport.GotoBottom()
 		}
-		m.UpdateViewport()
-		m.Viewport.GotoBottom()
-		return m, nil
+		return m, cmd
 
-	case ErrMsg:
-		m.Loading = false
-		m.StreamingContent = ""
-		if m.CancelFn != nil {
-			m.CancelFn()
-			m.CancelFn = nil
-		}
-		m.Err = msg
-		m.Messages = append(m.Messages, styles.ErrorStyle.Render(fmt.Sprintf("Error: %v", msg)))
+	case NotifyErrMsg:
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Notify command: %v", msg.Err)))
 		m.UpdateViewport()
 		m.Viewport.GotoBottom()
 		return m, nil
 
 	case tea.WindowSizeMsg:
-		m.WindowWidth = msg.Width
-		m.WindowHeight = msg.Height
-
-		// Update modal dimensions
-		ModalWidth = msg.Width - 10
-		if ModalWidth > 60 {
-			ModalWidth = 60
-		}
-		if ModalWidth < 30 {
-			ModalWidth = 30
-		}
-		styles.ContentWidth = ModalWidth - 6
-
-		// Update Viewport sizes
-		m.ModelViewport.Width = styles.ContentWidth
-		m.ModelViewport.Height = msg.Height - 15
-		if m.ModelViewport.Height > 20 {
-			m.ModelViewport.Height = 20
-		}
-		if m.ModelViewport.Height < 5 {
-			m.ModelViewport.Height = 5
-		}
-
-		// Full width mode (no sidebar)
-		// Reserve 2 lines for bottom bar + border
-		chatWidth := msg.Width - 2
-		m.Viewport.Width = chatWidth - 2
-
-		m.updateInputLayout()
-		glamourStyle := "dark"
-		if !lipgloss.HasDarkBackground() {
-			glamourStyle = "light"
-		}
-		m.Renderer, _ = glamour.NewTermRenderer(
-			glamour.WithStylePath(glamourStyle),
-			glamour.WithWordWrap(chatWidth-6),
-		)
+		m.Resize(msg.Width, msg.Height)
 		m.UpdateViewport()
 		return m, tea.Batch(tiCmd, vpCmd)
 	}
@@ -468,6 +671,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.TextInput.Reset()
 	}
 
+	m.updateCommandSuggestions()
+
 	// Check for @ file mention trigger
 	val = m.TextInput.Value()
 	cursorPos := TextareaCursorIndex(m.TextInput)
@@ -498,13 +703,104 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(tiCmd, vpCmd, mvpCmd)
 }
 
-func isNewlineShortcut(msg tea.KeyMsg) bool {
-	switch msg.String() {
-	case "shift+enter", "shift+return", "ctrl+j", "ctrl+enter", "alt+enter":
-		return true
-	default:
-		return false
+// applyRequestMsg updates the session for a message from its request. It
+// leaves the viewport alone, so background tabs are updated the same way.
+func (m *Model) applyRequestMsg(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case StreamChunkMsg:
+		m.clearRetryStatus()
+		m.StreamingContent += msg.Delta
+
+	case ReasoningChunkMsg:
+		m.clearRetryStatus()
+		m.StreamingReasoning += msg.Delta
+
+	case RetryMsg:
+		// Partial output from the failed attempt is discarded; the retry streams from scratch
+		m.StreamingContent = ""
+		m.StreamingReasoning = ""
+		m.RetryStatus = retry.Describe(msg.Attempt.Err)
+		m.RetryModel = msg.Attempt.Model
+		m.RetryUntil = time.Now().Add(msg.Attempt.Delay)
+
+	case CancelledMsg:
+		m.clearRetryStatus()
+		m.Loading = false
+		m.StreamingContent = ""
+		m.StreamingReasoning = ""
+		m.ExecutingTool = ""
+		m.ToolArguments = ""
+		m.ToolActions = nil
+		m.CancelFn = nil
+		m.endTurn()
+
+	case SteerMsg:
+		if err := m.addSteering(msg.Text); err != nil {
+			m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+		}
+
+	case ToolCallMsg:
+		m.clearRetryStatus()
+		m.ExecutingTool = msg.Name
+		m.ToolArguments = msg.Arguments
+
+	case ToolResultMsg:
+		m.ExecutingTool = ""
+		m.ToolArguments = ""
+		// Store the completed tool action for display
+		m.ToolActions = append(m.ToolActions, models.ToolAction{
+			Name:    msg.Name,
+			Summary: msg.Summary,
+		})
+
+	case ResponseMsg:
+		m.clearRetryStatus()
+		m.Loading = false
+		m.StreamingContent = ""
+		m.StreamingReasoning = ""
+		if m.CancelFn != nil {
+			m.CancelFn()
+			m.CancelFn = nil
+		}
+		m.InputTokens += msg.PromptTokens
+		m.OutputTokens += msg.CompletionTokens
+		m.History = msg.History
+		m.ContextTokens = msg.ContextTokens
+		m.Messages = append(m.Messages, ChatMessage{
+			Role:      models.RoleAssistant,
+			Content:   msg.Content,
+			ToolCalls: msg.ToolCalls,
+			Reasoning: msg.Reasoning,
+			CreatedAt: time.Now(),
+		})
+		answerIdx := len(m.Messages) - 1
+		m.ToolActions = nil // Clear for next response
+		if err := m.PersistAssistantMessage(msg); err != nil {
+			m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+		} else if n := len(m.Path); n > 0 {
+			m.Messages[answerIdx].SiblingIndex = m.Path[n-1].SiblingIndex
+			m.Messages[answerIdx].SiblingCount = m.Path[n-1].SiblingCount
+		}
+		notify := m.notifyDone(nil)
+		m.endTurn()
+		return tea.Batch(notify, m.sendQueued())
+
+	case ErrMsg:
+		m.clearRetryStatus()
+		m.Loading = false
+		m.StreamingContent = ""
+		m.StreamingReasoning = ""
+		if m.CancelFn != nil {
+			m.CancelFn()
+			m.CancelFn = nil
+		}
+		m.Err = msg
+		notify := m.notifyDone(msg)
+		m.endTurn()
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("Error: %v", msg)))
+		return notify
 	}
+	return nil
 }
 
 func (m *Model) updateInputLayout() {
@@ -543,21 +839,84 @@ func (m *Model) updateInputLayout() {
 	m.Viewport.Height = viewportHeight
 }
 
+// sendInput shows a prompt, stores it and starts the request for it
+func (m *Model) sendInput(input string) tea.Cmd {
+	// A doubled slash sends a message that starts with "/"
+	if strings.HasPrefix(input, "//") {
+		input = input[1:]
+	}
+
+	// Sending an edited message forks the chat at that point
+	if m.EditingMessageID != 0 {
+		m.forkAtEdit()
+	}
+
+	// Extract file mentions and build context
+	cleanInput, files := ExtractFileMentions(input)
+	m.AttachedFiles = files
+
+	// Display message shows clean input but indicates attached files
+	displayInput := cleanInput
+	if len(files) > 0 {
+		fileNames := make([]string, len(files))
+		for i, f := range files {
+			fileNames[i] = filepath.Base(f)
+		}
+		displayInput = fmt.Sprintf("%s\n📎 %s", cleanInput, strings.Join(fileNames, ", "))
+	}
+
+	m.reloadInstructions()
+	m.FocusedMessage = -1
+	m.Messages = append(m.Messages, ChatMessage{Role: models.RoleUser, Content: displayInput, Prompt: input, CreatedAt: time.Now()})
+	userIdx := len(m.Messages) - 1
+	if err := m.PersistUserMessage(input); err != nil {
+		m.AddNote(styles.ErrorStyle.Render(fmt.Sprintf("History error: %v", err)))
+	} else if n := len(m.Path); n > 0 {
+		m.Messages[userIdx].SiblingIndex = m.Path[n-1].SiblingIndex
+		m.Messages[userIdx].SiblingCount = m.Path[n-1].SiblingCount
+	}
+	return m.startRequest(input)
+}
+
+// startRequest shows the loading state and sends input to the model
+func (m *Model) startRequest(input string) tea.Cmd {
+	m.Loading = true
+	m.StreamingContent = ""
+	m.StreamingReasoning = ""
+	m.Steering = &steerQueue{}
+	m.RequestStart = time.Now()
+	if m.inFront() {
+		m.UpdateViewport()
+		m.Viewport.GotoBottom()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.CancelFn = cancel
+	return tea.Batch(m.SendMessage(ctx, input), m.Spinner.Tick)
+}
+
 func (m *Model) ResetSession() {
 	if m.CancelFn != nil {
 		m.CancelFn()
 		m.CancelFn = nil
 	}
-	m.Messages = []string{}
+	m.Messages = []ChatMessage{}
+	m.FocusedMessage = -1
 	m.History = []openai.ChatCompletionMessageParamUnion{}
 	m.CurrentChatID = 0
+	m.SystemPrompt = ""
+	m.Path = nil
+	m.LeafMessageID = 0
+	m.EditingMessageID = 0
 	m.InputTokens = 0
 	m.OutputTokens = 0
 	m.ContextTokens = 0
 	m.Loading = false
 	m.StreamingContent = ""
+	m.StreamingReasoning = ""
 	m.ExecutingTool = ""
 	m.ToolActions = nil
+	m.clearRetryStatus()
 	m.HistoryOpen = false
 	m.HistoryErr = nil
 	m.Viewport.SetContent(GetWelcomeScreen(m.Viewport.Width, m.Viewport.Height, m.MouseHoverArt))
@@ -566,6 +925,26 @@ func (m *Model) ResetSession() {
 	m.updateInputLayout()
 }
 
+// OpenModelSelector shows the model picker
+func (m *Model) OpenModelSelector() {
+	m.ModelSelectorOpen = true
+	m.HistoryOpen = false
+	m.ShortcutsOpen = false
+	m.PersonaSelectorOpen = false
+	m.UpdateModelSelectorContent() // Initial render
+	m.SyncModelViewportScroll()    // Initial scroll sync
+}
+
+// OpenHistory shows the stored chats of the current project
+func (m *Model) OpenHistory() {
+	m.ModelSelectorOpen = false
+	m.HistoryOpen = true
+	m.ShortcutsOpen = false
+	m.PersonaSelectorOpen = false
+	m.HistoryPage = 0
+	m.RefreshHistoryFromDB()
+}
+
 func (m *Model) RefreshHistoryFromDB() {
 	m.HistoryErr = nil
 	m.HistoryChats = nil
@@ -581,7 +960,11 @@ func (m *Model) RefreshHistoryFromDB() {
 	}
 
 	offset := m.HistoryPage * HistoryPageSize
-	count, chats, err := db.GetRecentChats(m.DB, HistoryPageSize, offset)
+	scope := ""
+	if !m.HistoryAllProjects {
+		scope = m.ProjectKey()
+	}
+	count, chats, err := db.GetRecentChats(m.DB, scope, HistoryPageSize, offset)
 	if err != nil {
 		m.HistoryErr = err
 		return
@@ -600,20 +983,36 @@ func (m *Model) PersistUserMessage(content string) error {
 
 	nowUnix := time.Now().Unix()
 	if m.CurrentChatID == 0 {
-		id, err := db.CreateChat(m.DB, nowUnix, m.CurrentModel.ID)
+		id, err := db.CreateChat(m.DB, models.ChatListItem{
+			CreatedAtUnix: nowUnix,
+			ModelID:       m.CurrentModel.ID,
+			Mode:          m.AppMode,
+			WorkingDir:    m.WorkingDir,
+			GitRoot:       m.GitRoot,
+			SystemPrompt:  m.basePrompt(),
+			Persona:       m.Persona,
+		})
 		if err != nil {
 			return err
 		}
 		m.CurrentChatID = id
 	}
 
-	if err := db.InsertDBMessage(m.DB, m.CurrentChatID, models.RoleUser, content, nowUnix); err != nil {
+	id, err := db.InsertDBMessage(m.DB, m.CurrentChatID, models.DBMessage{
+		ParentID:      m.LeafMessageID,
+		Role:          models.RoleUser,
+		Content:       content,
+		CreatedAtUnix: nowUnix,
+	})
+	if err != nil {
 		return err
 	}
-	return db.UpdateChatOnUser(m.DB, m.CurrentChatID, nowUnix, m.CurrentModel.ID, PromptPreview(content))
+	m.LeafMessageID = id
+	m.refreshPath()
+	return db.UpdateChatOnUser(m.DB, m.CurrentChatID, nowUnix, m.CurrentModel.ID, m.AppMode, m.basePrompt(), db.PromptPreview(content))
 }
 
-func (m *Model) PersistAssistantMessage(content string) error {
+func (m *Model) PersistAssistantMessage(resp ResponseMsg) error {
 	if m.CurrentChatID == 0 {
 		return nil
 	}
@@ -625,13 +1024,66 @@ func (m *Model) PersistAssistantMessage(content string) error {
 	}
 
 	nowUnix := time.Now().Unix()
-	if err := db.InsertDBMessage(m.DB, m.CurrentChatID, models.RoleAssistant, content, nowUnix); err != nil {
+	id, err := db.InsertDBMessage(m.DB, m.CurrentChatID, models.DBMessage{
+		ParentID:         m.LeafMessageID,
+		Role:             models.RoleAssistant,
+		Content:          resp.Content,
+		CreatedAtUnix:    nowUnix,
+		ModelID:          resp.ModelID,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		ToolCalls:        resp.ToolCalls,
+		Reasoning:        resp.Reasoning,
+	})
+	if err != nil {
 		return err
 	}
+	m.LeafMessageID = id
+	m.refreshPath()
 	return db.TouchChat(m.DB, m.CurrentChatID, nowUnix)
 }
 
-func (m *Model) LoadChatFromDB(chatID int64, modelID string) error {
+// Resize lays out the UI for a terminal of the given size and rebuilds the
+// Markdown renderer for the new width
+func (m *Model) Resize(width, height int) {
+	m.WindowWidth = width
+	m.WindowHeight = height
+
+	// Update modal dimensions
+	ModalWidth = width - 10
+	if ModalWidth > 60 {
+		ModalWidth = 60
+	}
+	if ModalWidth < 30 {
+		ModalWidth = 30
+	}
+	styles.ContentWidth = ModalWidth - 6
+
+	// Update Viewport sizes
+	m.ModelViewport.Width = styles.ContentWidth
+	m.ModelViewport.Height = height - 15
+	if m.ModelViewport.Height > 20 {
+		m.ModelViewport.Height = 20
+	}
+	if m.ModelViewport.Height < 5 {
+		m.ModelViewport.Height = 5
+	}
+
+	// Full width mode (no sidebar)
+	// Reserve 2 lines for bottom bar + border
+	chatWidth := width - 2
+	m.Viewport.Width = chatWidth - 2
+
+	m.updateInputLayout()
+	// Messages are re-wrapped with the new renderer on the next update
+	for i := range m.Messages {
+		m.Messages[i].rendered = ""
+	}
+	m.newRenderer(chatWidth - 6)
+}
+
+// LoadChatFromDB opens a stored chat, restoring the model and mode it last used
+func (m *Model) LoadChatFromDB(chat models.ChatListItem) error {
 	if m.DBErr != nil {
 		return m.DBErr
 	}
@@ -639,62 +1091,113 @@ func (m *Model) LoadChatFromDB(chatID int64, modelID string) error {
 		return fmt.Errorf("history database not initialized")
 	}
 
-	msgs, err := db.GetChatMessages(m.DB, chatID)
+	msgs, err := db.GetChatMessages(m.DB, chat.ID)
 	if err != nil {
 		return err
 	}
 
-	if modelID != "" {
-		if mdl, idx, ok := FindModelByID(modelID); ok {
-			m.CurrentModel = mdl
-			m.SelectedModelIndex = idx
-		} else {
-			m.CurrentModel = models.AIModel{ID: modelID, Name: modelID, Provider: "Unknown"}
-			m.SelectedModelIndex = 0
-		}
+	if chat.ModelID != "" {
+		m.SetModelByID(chat.ModelID)
+	}
+	m.AppMode = chat.Mode
+	m.Persona = chat.Persona
+	m.SystemPrompt = instructions.Strip(chat.SystemPrompt)
+	if chat.WorkingDir != m.WorkingDir {
+		// The stored prompt describes another directory
+		m.SystemPrompt = ""
 	}
 
-	m.CurrentChatID = chatID
+	m.CurrentChatID = chat.ID
 	m.Loading = false
 	m.InputTokens = 0
 	m.OutputTokens = 0
-	m.Messages = []string{}
-	m.History = []openai.ChatCompletionMessageParamUnion{}
+	m.EditingMessageID = 0
+	m.showBranch(msgs)
 
-	for _, msg := range msgs {
-		switch msg.Role {
-		case models.RoleUser:
-			m.Messages = append(m.Messages, FormatUserMessage(msg.Content, m.Viewport.Width, len(m.Messages) == 0))
-			m.History = append(m.History, openai.UserMessage(msg.Content))
-		case models.RoleAssistant:
-			displayContent := msg.Content
-			if m.Renderer != nil {
-				rendered, _ := m.Renderer.Render(msg.Content)
-				displayContent = strings.TrimSpace(rendered)
-			}
-			m.Messages = append(m.Messages, FormatAIMessage(displayContent))
-			m.History = append(m.History, openai.AssistantMessage(msg.Content))
+	if _, ok := m.Config.Persona(chat.Persona); chat.Persona != "" && !ok {
+		// Its prompt and tool list are gone with it, so go back to the
+		// default persona entirely rather than keep half of it
+		if err := m.SetPersona(""); err != nil {
+			return err
 		}
+		m.AddNote(styles.WarningStyle.Render(fmt.Sprintf(
+			"⚠ Persona %q is no longer defined in config; switched to the default persona", chat.Persona)))
+		m.UpdateViewport()
+	}
+	if chat.Mode == models.ModeAgent && chat.WorkingDir != "" && chat.WorkingDir != m.WorkingDir {
+		m.AddNote(styles.WarningStyle.Render(fmt.Sprintf(
+			"⚠ This agent chat was started in %s; tools will now run in %s", chat.WorkingDir, m.WorkingDir)))
+		m.UpdateViewport()
 	}
-
-	m.UpdateViewport()
 	return nil
 }
 
+// SetModelByID selects a model, accepting IDs missing from AvailableModels
+func (m *Model) SetModelByID(id string) {
+	if mdl, idx, ok := FindModelByID(id); ok {
+		m.CurrentModel = mdl
+		m.SelectedModelIndex = idx
+		return
+	}
+	m.CurrentModel = models.AIModel{ID: id, Name: id, Provider: "Unknown"}
+	m.SelectedModelIndex = 0
+}
+
+// ProjectKey identifies the current project for history scoping
+func (m *Model) ProjectKey() string {
+	return project.Key(m.WorkingDir, m.GitRoot)
+}
+
+// RetryPolicy returns the retry policy from the user config
+func (m *Model) RetryPolicy() retry.Policy {
+	return retry.PolicyFromConfig(m.Config.Retry)
+}
+
+// ModelCandidates returns the current model followed by configured fallbacks
+func (m *Model) ModelCandidates() []string {
+	candidates := []string{m.CurrentModel.ID}
+	for _, id := range m.Config.FallbackModels {
+		if id != "" && !slices.Contains(candidates, id) {
+			candidates = append(candidates, id)
+		}
+	}
+	return candidates
+}
+
+func (m *Model) clearRetryStatus() {
+	m.RetryStatus = ""
+	m.RetryModel = ""
+	m.RetryUntil = time.Time{}
+}
+
 func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 	// Capture attached files before returning the command
 	attachedFiles := m.AttachedFiles
 	m.AttachedFiles = nil // Clear for next message
 
-	return func() tea.Msg {
-		// Build system prompt based on mode
-		var systemPrompt string
-		if m.AppMode == models.ModeAgent {
-			cwd, _ := os.Getwd()
-			systemPrompt = fmt.Sprintf(AgentSystemPrompt, cwd)
-		} else {
-			systemPrompt = ChatSystemPrompt
+	// Use the prompt stored with the chat so a resumed conversation keeps it
+	systemPrompt := m.ActiveSystemPrompt()
+	allowedTools := m.AllowedTools()
+
+	// The request keeps running while other tabs are shown, so it works from
+	// a snapshot of this session and tags what it sends with the session ID
+	sessionID := m.ID
+	mode := m.AppMode
+	prior := m.History
+	modelID := m.CurrentModel.ID
+	candidates := m.ModelCandidates()
+	maxTokens := m.GetMaxContextTokens()
+	policy := m.RetryPolicy()
+	efforts := m.reasoningEfforts()
+	steer := m.Steering
+	send := func(msg tea.Msg) {
+		if m.Program != nil {
+			m.Program.Send(TabMsg{Session: sessionID, Msg: msg})
 		}
+	}
+	notifyRetry := func(a retry.Attempt) { send(RetryMsg{Attempt: a}) }
+
+	run := func() tea.Msg {
 
 		// Extract clean input and build file context
 		cleanInput, _ := ExtractFileMentions(input)
@@ -710,37 +1213,54 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 		history := []openai.ChatCompletionMessageParamUnion{
 			openai.SystemMessage(systemPrompt),
 		}
-		history = append(history, m.History...)
+		history = append(history, prior...)
 		history = append(history, openai.UserMessage(userMessage))
 
 		var totalPromptTokens int64
 		var totalCompletionTokens int64
 
 		// Chat mode: streaming API call without tools
-		if m.AppMode == models.ModeChat {
-			stream := m.Client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
-				Model:    m.CurrentModel.ID,
-				Messages: history,
-			})
-			acc := openai.ChatCompletionAccumulator{}
-			for stream.Next() {
-				chunk := stream.Current()
-				acc.AddChunk(chunk)
-				if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-					if m.Program != nil {
-						m.Program.Send(StreamChunkMsg{Delta: chunk.Choices[0].Delta.Content})
+		if mode == models.ModeChat {
+			var acc openai.ChatCompletionAccumulator
+			var reasoning strings.Builder
+			answeredBy, err := retry.Do(ctx, policy, candidates, func(ctx context.Context, model string) error {
+				acc = openai.ChatCompletionAccumulator{}
+				reasoning.Reset()
+				stream := m.Client.Chat.Completions.NewStreaming(ctx, withReasoningEffort(openai.ChatCompletionNewParams{
+					Model:    model,
+					Messages: history,
+				}, efforts[model]))
+				defer stream.Close()
+				for stream.Next() {
+					chunk := stream.Current()
+					acc.AddChunk(chunk)
+					if len(chunk.Choices) == 0 {
+						continue
+					}
+					// The accumulator drops fields the API does not define, so
+					// thinking is collected here
+					if delta := reasoningText(chunk.Choices[0].Delta.JSON.ExtraFields); delta != "" {
+						reasoning.WriteString(delta)
+						send(ReasoningChunkMsg{Delta: delta})
+					}
+					if chunk.Choices[0].Delta.Content != "" {
+						send(StreamChunkMsg{Delta: chunk.Choices[0].Delta.Content})
 					}
 				}
-			}
-			if err := stream.Err(); err != nil {
+				if err := stream.Err(); err != nil {
+					return err
+				}
+				if len(acc.Choices) == 0 {
+					return fmt.Errorf("empty response from model")
+				}
+				return nil
+			}, notifyRetry)
+			if err != nil {
 				if ctx.Err() != nil {
 					return CancelledMsg{}
 				}
 				return ErrMsg(err)
 			}
-			if len(acc.Choices) == 0 {
-				return ErrMsg(fmt.Errorf("empty response from model"))
-			}
 			fullContent := acc.Choices[0].Message.Content
 			storedHistory := history[1:]
 			storedHistory = append(storedHistory, acc.Choices[0].Message.ToParam())
@@ -750,11 +1270,15 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 				CompletionTokens: acc.Usage.CompletionTokens,
 				History:          storedHistory,
 				ContextTokens:    EstimateHistoryTokens(storedHistory),
+				ModelID:          answeredBy,
+				Reasoning:        reasoning.String(),
 			}
 		}
 
 		// Agent mode: agentic loop with tools, parallel execution, cancellation
 		var toolExecs []ToolExecRecord
+		var reasoning []string // Thinking from each round, shown together
+		answeredBy := modelID
 		iteration := 0
 		for {
 			iteration++
@@ -764,20 +1288,32 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 				return CancelledMsg{}
 			}
 
-			// Compact history if approaching context limit
-			history = CompactHistory(history, m.GetMaxContextTokens())
+			// Steering messages join the conversation between tool rounds
+			for _, text := range steer.drain() {
+				history = append(history, openai.UserMessage(text))
+				send(SteerMsg{Text: text})
+			}
 
-			resp, err := m.Client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-				Model:    m.CurrentModel.ID,
-				Messages: history,
-				Tools:    tools.Definitions,
-			})
+			// Compact history if approaching context limit
+			history = CompactHistory(history, maxTokens)
+
+			var resp *openai.ChatCompletion
+			model, err := retry.Do(ctx, policy, candidates, func(ctx context.Context, model string) error {
+				var err error
+				resp, err = m.Client.Chat.Completions.New(ctx, withReasoningEffort(openai.ChatCompletionNewParams{
+					Model:    model,
+					Messages: history,
+					Tools:    tools.Allowed(allowedTools),
+				}, efforts[model]))
+				return err
+			}, notifyRetry)
 			if err != nil {
 				if ctx.Err() != nil {
 					return CancelledMsg{}
 				}
 				return ErrMsg(err)
 			}
+			answeredBy = model
 
 			totalPromptTokens += resp.Usage.PromptTokens
 			totalCompletionTokens += resp.Usage.CompletionTokens
@@ -787,6 +1323,13 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 			}
 
 			choice := resp.Choices[0]
+			if text := strings.TrimSpace(reasoningText(choice.Message.JSON.ExtraFields)); text != "" {
+				if len(reasoning) > 0 {
+					send(ReasoningChunkMsg{Delta: "\n\n"})
+				}
+				reasoning = append(reasoning, text)
+				send(ReasoningChunkMsg{Delta: text})
+			}
 			inlineName, inlineArgs, inlineOK := ParseInlineToolCall(choice.Message.Content)
 
 			// Some providers/models include speculative user-facing content alongside tool calls.
@@ -811,6 +1354,9 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 					CompletionTokens: totalCompletionTokens,
 					History:          storedHistory,
 					ContextTokens:    EstimateHistoryTokens(storedHistory),
+					ModelID:          answeredBy,
+					ToolCalls:        ToolCallsFromExecs(toolExecs),
+					Reasoning:        strings.Join(reasoning, "\n\n"),
 				}
 			}
 
@@ -830,10 +1376,8 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 					wg.Add(1)
 					go func(i int, tc openai.ChatCompletionMessageToolCallUnion) {
 						defer wg.Done()
-						if m.Program != nil {
-							m.Program.Send(ToolCallMsg{Name: tc.Function.Name, Arguments: tc.Function.Arguments})
-						}
-						res, err := tools.ExecuteTool(tc.Function.Name, tc.Function.Arguments)
+						send(ToolCallMsg{Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+						res, err := executeAllowedTool(tc.Function.Name, tc.Function.Arguments, allowedTools)
 						if err != nil {
 							res = fmt.Sprintf("error: %v", err)
 						}
@@ -855,30 +1399,24 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 				}
 
 				for _, r := range results {
-					toolExecs = append(toolExecs, ToolExecRecord{Name: r.name, Args: r.args, Result: r.result})
+					toolExecs = append(toolExecs, ToolExecRecord{Name: r.name, Args: r.args, Result: r.result, Summary: r.summary})
 					history = append(history, openai.ToolMessage(r.id, r.result))
-					if m.Program != nil {
-						m.Program.Send(ToolResultMsg{Name: r.name, Result: r.result, Summary: r.summary})
-					}
+					send(ToolResultMsg{Name: r.name, Result: r.result, Summary: r.summary})
 				}
 				continue
 			}
 
 			// GLM-style inline tool call fallback (e.g. `ls{}` in content with no tool_calls)
 			if inlineOK {
-				if m.Program != nil {
-					m.Program.Send(ToolCallMsg{Name: inlineName, Arguments: inlineArgs})
-				}
-				result, err := tools.ExecuteTool(inlineName, inlineArgs)
+				send(ToolCallMsg{Name: inlineName, Arguments: inlineArgs})
+				result, err := executeAllowedTool(inlineName, inlineArgs, allowedTools)
 				if err != nil {
 					result = fmt.Sprintf("error: %v", err)
 				}
-				toolExecs = append(toolExecs, ToolExecRecord{Name: inlineName, Args: inlineArgs, Result: result})
+				summary := tools.GenerateToolSummary(inlineName, inlineArgs, result)
+				toolExecs = append(toolExecs, ToolExecRecord{Name: inlineName, Args: inlineArgs, Result: result, Summary: summary})
 				history = append(history, openai.AssistantMessage(fmt.Sprintf("Tool %s result:\n%s", inlineName, result)))
-				if m.Program != nil {
-					summary := tools.GenerateToolSummary(inlineName, inlineArgs, result)
-					m.Program.Send(ToolResultMsg{Name: inlineName, Result: result, Summary: summary})
-				}
+				send(ToolResultMsg{Name: inlineName, Result: result, Summary: summary})
 				continue
 			}
 
@@ -890,7 +1428,13 @@ func (m *Model) SendMessage(ctx context.Context, input string) tea.Cmd {
 				CompletionTokens: totalCompletionTokens,
 				History:          storedHistory,
 				ContextTokens:    EstimateHistoryTokens(storedHistory),
+				ModelID:          answeredBy,
+				ToolCalls:        ToolCallsFromExecs(toolExecs),
+				Reasoning:        strings.Join(reasoning, "\n\n"),
 			}
 		}
 	}
+	return func() tea.Msg {
+		return TabMsg{Session: sessionID, Msg: run()}
+	}
 }
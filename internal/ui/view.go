@@ -1,8 +1,12 @@
 package ui
 
 import (
+	"arcane/internal/db"
+	"arcane/internal/models"
+	"arcane/internal/project"
 	"arcane/internal/styles"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,12 +23,8 @@ func (m *Model) UpdateModelSelectorContent() {
 			if lastProvider != "" {
 				items = append(items, "")
 			}
-			providerColor := "#545454"
-			if c, ok := styles.ProviderColors[mdl.Provider]; ok {
-				providerColor = c
-			}
 			header := styles.ModalHeaderStyle.Copy().
-				Foreground(lipgloss.Color(providerColor)).
+				Foreground(styles.CurrentTheme.ProviderColor(mdl.Provider)).
 				Render(mdl.Provider)
 			items = append(items, header)
 			lastProvider = mdl.Provider
@@ -59,9 +59,9 @@ func (m *Model) UpdateModelSelectorContent() {
 			// Normal style
 			style := styles.ModalItemStyle.Copy().Width(styles.ContentWidth)
 			if isCurrent {
-				style = style.Foreground(lipgloss.Color(styles.Cyan)) // Highlight active model text
+				style = style.Foreground(styles.CurrentTheme.Secondary) // Highlight active model text
 			} else {
-				style = style.Foreground(lipgloss.AdaptiveColor{Light: "#1a1a2e", Dark: styles.TextPrimary})
+				style = style.Foreground(styles.CurrentTheme.Text)
 			}
 			styledItem = style.Render(displayName)
 		}
@@ -97,14 +97,22 @@ func (m *Model) RenderHistorySelector() string {
 	if totalPages < 1 {
 		totalPages = 1
 	}
-	title := styles.ModalTitleStyle.Render(fmt.Sprintf("Recent Chats (%d) - Page %d/%d", m.HistoryChatCount, m.HistoryPage+1, totalPages))
+	scope := "All Projects"
+	if !m.HistoryAllProjects {
+		scope = project.Name(m.WorkingDir, m.GitRoot)
+	}
+	title := styles.ModalTitleStyle.Render(fmt.Sprintf("Recent Chats · %s (%d) - Page %d/%d", scope, m.HistoryChatCount, m.HistoryPage+1, totalPages))
 
 	var body string
 	if m.HistoryErr != nil {
 		errLine := lipgloss.NewStyle().Width(styles.ContentWidth).Render(styles.ErrorStyle.Render(fmt.Sprintf("Error: %v", m.HistoryErr)))
 		body = errLine
 	} else if len(m.HistoryChats) == 0 {
-		body = styles.ModalItemStyle.Render(lipgloss.NewStyle().Foreground(styles.HintColor).Render("No chats yet"))
+		empty := "No chats yet"
+		if !m.HistoryAllProjects {
+			empty = "No chats in this project yet (P: show all projects)"
+		}
+		body = styles.ModalItemStyle.Render(lipgloss.NewStyle().Foreground(styles.HintColor).Render(empty))
 	} else {
 		items := make([]string, 0, len(m.HistoryChats))
 		for i, chat := range m.HistoryChats {
@@ -114,10 +122,13 @@ func (m *Model) RenderHistorySelector() string {
 				cursor = "> "
 			}
 			timeStr := RelativeTime(time.Unix(chat.UpdatedAtUnix, 0))
-			prompt := PromptPreview(chat.LastUserPrompt)
+			prompt := db.PromptPreview(chat.LastUserPrompt)
 			if prompt == "" {
 				prompt = "(no prompt)"
 			}
+			if name := project.Name(chat.WorkingDir, chat.GitRoot); m.HistoryAllProjects && name != "" {
+				prompt = "[" + name + "] " + prompt
+			}
 
 			// We have styles.ContentWidth (54)
 			// Styles have Padding(0, 1), so effective inner width is 52
@@ -125,7 +136,7 @@ func (m *Model) RenderHistorySelector() string {
 
 			timeStyle := lipgloss.NewStyle().Foreground(styles.HintColor)
 			if isSelected {
-				timeStyle = timeStyle.Foreground(lipgloss.Color(styles.Cyan))
+				timeStyle = timeStyle.Foreground(styles.CurrentTheme.Secondary)
 			}
 			styledTime := timeStyle.Render(timeStr)
 			timeWidth := lipgloss.Width(styledTime)
@@ -157,7 +168,7 @@ func (m *Model) RenderHistorySelector() string {
 		Foreground(styles.HintColor).
 		Width(styles.ContentWidth).
 		PaddingTop(1).
-		Render("↑/↓: navigate • ←/→: page • Enter: open • Esc: close")
+		Render("↑/↓: navigate • ←/→: page • Enter: open • F: fork • P: this project/all • Esc: close")
 
 	return lipgloss.JoinVertical(lipgloss.Left, content, hint)
 }
@@ -165,29 +176,35 @@ func (m *Model) RenderHistorySelector() string {
 func (m *Model) RenderShortcutsModal() string {
 	title := styles.ModalTitleStyle.Render("Keyboard Shortcuts")
 
-	shortcuts := []struct {
+	type shortcut struct {
 		key  string
 		desc string
-	}{
-		{"Ctrl+C", "Quit Application"},
-		{"Ctrl+N", "New Chat Session"},
-		{"Ctrl+A", "Toggle Agent/Chat Mode"},
-		{"Ctrl+B", "Select AI Model"},
-		{"Ctrl+H", "View Chat History"},
-		{"Ctrl+S", "View Shortcuts (this menu)"},
-		{"Shift+Enter/Ctrl+J", "New line in input"},
-		{"@", "Mention File (in input)"},
-		{"Ctrl+L", "Clear Screen (standard)"},
+	}
+	var shortcuts []shortcut
+	for _, a := range m.Keys.actions() {
+		if a.Binding.Enabled() {
+			shortcuts = append(shortcuts, shortcut{a.Binding.Help().Key, a.Binding.Help().Desc})
+		}
+	}
+	shortcuts = append(shortcuts,
+		shortcut{"Esc", "Close popup / cancel / quit"},
+		shortcut{"@", "Mention file (in input)"},
+		shortcut{"/", "Slash commands (/help lists them)"},
+	)
+
+	keyWidth := 0
+	for _, s := range shortcuts {
+		keyWidth = max(keyWidth, lipgloss.Width(s.key))
 	}
 
 	var items []string
 	keyStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.Amber)).
+		Foreground(styles.CurrentTheme.Highlight).
 		Bold(true).
-		Width(12)
+		Width(keyWidth)
 
 	descStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.TextSecondary))
+		Foreground(styles.CurrentTheme.TextSecondary)
 
 	for _, s := range shortcuts {
 		line := fmt.Sprintf("%s %s", keyStyle.Render(s.key), descStyle.Render(s.desc))
@@ -201,7 +218,7 @@ func (m *Model) RenderShortcutsModal() string {
 		Foreground(styles.HintColor).
 		Width(styles.ContentWidth).
 		PaddingTop(1).
-		Render("Esc/Enter: close")
+		Render("Esc/Enter: close • rebind under \"keys\" in config.json")
 
 	return lipgloss.JoinVertical(lipgloss.Left, content, hint)
 }
@@ -209,18 +226,25 @@ func (m *Model) RenderShortcutsModal() string {
 func (m *Model) RenderBottomBar() string {
 	// 1. Mode Badge (Left)
 	modeBadge := "CHAT"
-	modeColor := styles.Rose
+	modeColor := styles.CurrentTheme.ModeChat
 	if m.AppMode == 1 { // models.ModeAgent
 		modeBadge = "AGENT"
-		modeColor = styles.Pink
+		modeColor = styles.CurrentTheme.ModeAgent
 	}
 	mode := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("#0E1525")).
-		Background(lipgloss.Color(modeColor)).
+		Foreground(styles.CurrentTheme.OnColor).
+		Background(modeColor).
 		Padding(0, 1).
 		Render(modeBadge)
 
+	if m.Persona != "" {
+		mode = lipgloss.JoinHorizontal(lipgloss.Center, mode, lipgloss.NewStyle().
+			Foreground(modeColor).
+			Padding(0, 1).
+			Render(m.Persona))
+	}
+
 	// 2. Working Directory
 	cwdDisplay := m.WorkingDir
 	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(cwdDisplay, home) {
@@ -228,7 +252,7 @@ func (m *Model) RenderBottomBar() string {
 	}
 	cwdDisplay = TruncateRunes(cwdDisplay, 30)
 	cwd := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.TextMuted)).
+		Foreground(styles.CurrentTheme.TextMuted).
 		Render(cwdDisplay)
 
 	// 4. Context Window
@@ -237,33 +261,47 @@ func (m *Model) RenderBottomBar() string {
 	if m.ContextTokens > 0 && maxCtx > 0 {
 		contextPct = int(float64(m.ContextTokens) / float64(maxCtx) * 100)
 	}
-	ctxColor := styles.TextMuted
+	ctxColor := styles.CurrentTheme.TextMuted
 	if contextPct > 80 {
-		ctxColor = styles.ErrRed
+		ctxColor = styles.CurrentTheme.Error
 	} else if contextPct > 60 {
-		ctxColor = styles.ErrAmber
+		ctxColor = styles.CurrentTheme.Warning
 	}
 
 	ctxText := fmt.Sprintf("%d%% (%dk/%dk)", contextPct, m.ContextTokens/1000, maxCtx/1000)
 	ctx := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(ctxColor)).
+		Foreground(ctxColor).
 		Render(ctxText)
 
 	// 5. Token Usage
 	tokens := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.TextDim)).
+		Foreground(styles.CurrentTheme.TextDim).
 		Render(fmt.Sprintf("in:%d out:%d", m.InputTokens, m.OutputTokens))
 
 	// 6. Help Hint (Far Right)
+	helpText := "/help"
+	if k := m.KeyHelp("shortcuts"); k != "" {
+		helpText = k + " help"
+	}
 	help := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#334155")).
-		Render("^S help")
+		Foreground(styles.CurrentTheme.Divider).
+		Render(helpText)
 
 	// Spacer to push items apart
 	// We want: [Mode] [CWD] ...spacer... [Context] [Tokens] [Help]
 
 	leftSide := lipgloss.JoinHorizontal(lipgloss.Center, mode, "  ", cwd)
+	if len(m.Instructions) > 0 {
+		instr := lipgloss.NewStyle().
+			Foreground(styles.CurrentTheme.TextDim).
+			Render("📜 " + TruncateRunes(m.InstructionNames(), 40))
+		leftSide = lipgloss.JoinHorizontal(lipgloss.Center, leftSide, "  ", instr)
+	}
 	rightSide := lipgloss.JoinHorizontal(lipgloss.Center, ctx, "  ", tokens, "  ", help)
+	if status := m.ChatSearchStatus(); status != "" {
+		found := lipgloss.NewStyle().Foreground(styles.CurrentTheme.Highlight).Render("🔍 " + status)
+		rightSide = lipgloss.JoinHorizontal(lipgloss.Center, found, "  ", rightSide)
+	}
 
 	// Calculate available space for spacer
 	availableWidth := m.WindowWidth - lipgloss.Width(leftSide) - lipgloss.Width(rightSide) - 2 // -2 for padding
@@ -278,7 +316,7 @@ func (m *Model) RenderBottomBar() string {
 		Width(m.WindowWidth).
 		BorderTop(true).
 		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color(styles.BorderDark)).
+		BorderForeground(styles.CurrentTheme.Border).
 		Padding(0, 1).
 		Render(bar)
 }
@@ -289,13 +327,13 @@ func (m *Model) RenderPendingFiles() string {
 	}
 
 	chipStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#F1F5F9")).
-		Background(lipgloss.Color(styles.Violet)).
+		Foreground(styles.CurrentTheme.OnAccent).
+		Background(styles.CurrentTheme.Accent).
 		Padding(0, 1).
 		MarginRight(1)
 
 	labelStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.TextMuted))
+		Foreground(styles.CurrentTheme.TextMuted)
 
 	var chips []string
 	for _, file := range m.PendingFiles {
@@ -305,23 +343,44 @@ func (m *Model) RenderPendingFiles() string {
 	return labelStyle.Render("Attached: ") + strings.Join(chips, " ")
 }
 
+// RenderEditBanner explains what happens when an edited message is sent
+func (m *Model) RenderEditBanner() string {
+	if m.EditingMessageID == 0 {
+		return ""
+	}
+	pos, total := 0, 0
+	for _, msg := range m.Path {
+		if msg.Role != models.RoleUser {
+			continue
+		}
+		total++
+		if msg.ID == m.EditingMessageID {
+			pos = total
+		}
+	}
+	return lipgloss.NewStyle().
+		Foreground(styles.CurrentTheme.Highlight).
+		Italic(true).
+		Render(fmt.Sprintf("✎ Editing message %d/%d • Enter: resend as new branch • %s: earlier • Esc: cancel", pos, total, m.KeyHelp("edit_previous")))
+}
+
 func (m *Model) RenderFileSuggestions() string {
 	if !m.FileSuggestOpen || len(m.FileSuggestions) == 0 {
 		return ""
 	}
 
 	suggestionStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.TextSecondary)).
+		Foreground(styles.CurrentTheme.TextSecondary).
 		Padding(0, 1)
 
 	selectedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#F1F5F9")).
-		Background(lipgloss.Color(styles.Violet)).
+		Foreground(styles.CurrentTheme.OnAccent).
+		Background(styles.CurrentTheme.Accent).
 		Padding(0, 1)
 
 	var lines []string
 	header := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(styles.TextMuted)).
+		Foreground(styles.CurrentTheme.TextMuted).
 		Italic(true).
 		Render("  files (↑↓ select, Tab/Enter insert)")
 	lines = append(lines, header)
@@ -343,8 +402,8 @@ func (m *Model) RenderFileSuggestions() string {
 
 	popupStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color(styles.Rose)).
-		Background(lipgloss.Color(styles.BgDeep)).
+		BorderForeground(styles.CurrentTheme.Primary).
+		Background(styles.CurrentTheme.BgElevated).
 		Padding(0, 1)
 
 	return popupStyle.Render(strings.Join(lines, "\n"))
@@ -368,7 +427,7 @@ func GetWelcomeScreen(width, height int, hover bool) string {
 	artStyle := styles.WelcomeArtStyle.Copy()
 	if hover {
 		artStyle = artStyle.
-			Foreground(lipgloss.Color(styles.Violet)).
+			Foreground(styles.CurrentTheme.Accent).
 			Bold(true).
 			Italic(true)
 	}
@@ -381,30 +440,60 @@ func GetWelcomeScreen(width, height int, hover bool) string {
 	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, content)
 }
 
+// RetryStatusText describes a pending retry, e.g. "429 Too Many Requests · retrying in 4s"
+func (m *Model) RetryStatusText() string {
+	target := ""
+	if m.RetryModel != "" && m.RetryModel != m.CurrentModel.ID {
+		name := m.RetryModel
+		if mdl, _, ok := FindModelByID(m.RetryModel); ok {
+			name = mdl.Name
+		}
+		target = " on " + name
+	}
+	remaining := time.Until(m.RetryUntil)
+	if remaining > 0 {
+		secs := int(math.Ceil(remaining.Seconds()))
+		return fmt.Sprintf("%s · retrying%s in %ds", m.RetryStatus, target, secs)
+	}
+	return fmt.Sprintf("%s · retrying%s...", m.RetryStatus, target)
+}
+
 func (m *Model) UpdateViewport() {
 	if len(m.Messages) == 0 && !m.Loading {
 		m.Viewport.SetContent(GetWelcomeScreen(m.Viewport.Width, m.Viewport.Height, m.MouseHoverArt))
 		return
 	}
 
-	content := strings.Join(m.Messages, "\n\n")
+	content := m.transcript()
 	if m.Loading {
 		var loadingMsg string
 
 		if m.StreamingContent != "" {
-			// Show the partial streaming response as it arrives
-			label := styles.AiLabelStyle.Render("ARCANE")
-			partial := styles.AiMsgStyle.Render(m.StreamingContent + "▋")
-			loadingMsg = fmt.Sprintf("%s\n%s", label, partial)
+			// Show the partial streaming response as it arrives, with the
+			// thinking before it folded away
+			loadingParts := []string{styles.AiLabelStyle.Render("ARCANE")}
+			if thinking := m.formatReasoning(m.StreamingReasoning, false, m.Viewport.Width); thinking != "" {
+				loadingParts = append(loadingParts, thinking)
+			}
+			loadingParts = append(loadingParts, styles.AiMsgStyle.Render(m.StreamingContent+"▋"))
+			loadingMsg = strings.Join(loadingParts, "\n")
 		} else {
 			statusText := " Generating..."
-			if m.ExecutingTool != "" {
+			if m.RetryStatus != "" {
+				statusText = " " + m.RetryStatusText()
+			} else if m.ExecutingTool != "" {
 				statusText = fmt.Sprintf(" %s...", m.ExecutingTool)
+			} else if m.StreamingReasoning != "" {
+				statusText = " Thinking..."
 			}
 
-			// Build loading message with completed tool actions
+			// Build loading message with the thinking so far and completed
+			// tool actions
 			var loadingParts []string
 			loadingParts = append(loadingParts, styles.AiLabelStyle.Render("ARCANE"))
+			if thinking := m.formatReasoning(m.StreamingReasoning, true, m.Viewport.Width); thinking != "" {
+				loadingParts = append(loadingParts, thinking)
+			}
 
 			// Show completed tool actions
 			if len(m.ToolActions) > 0 {
@@ -431,6 +520,7 @@ func (m *Model) View() string {
 
 	// Render file suggestions popup if open
 	fileSuggestPopup := m.RenderFileSuggestions()
+	commandSuggestPopup := m.RenderCommandSuggestions()
 	pendingFilesDisplay := m.RenderPendingFiles()
 
 	// Full-width chat with bottom bar
@@ -441,23 +531,41 @@ func (m *Model) View() string {
 
 	// Model name displayed above input, right-aligned
 	modelNameLine := lipgloss.PlaceHorizontal(m.WindowWidth-4, lipgloss.Right,
-		lipgloss.NewStyle().Foreground(lipgloss.Color(styles.Cyan)).Italic(true).Render("⬡ "+m.CurrentModel.Name))
+		lipgloss.NewStyle().Foreground(styles.CurrentTheme.Secondary).Italic(true).Render("⬡ "+m.CurrentModel.Name))
 
 	var inputSection string
 	var inputParts []string
 	inputParts = append(inputParts, modelNameLine)
+	if banner := m.RenderEditBanner(); banner != "" {
+		inputParts = append(inputParts, banner)
+	}
 	if pendingFilesDisplay != "" {
 		inputParts = append(inputParts, pendingFilesDisplay)
 	}
 	if fileSuggestPopup != "" {
 		inputParts = append(inputParts, fileSuggestPopup)
 	}
+	if commandSuggestPopup != "" {
+		inputParts = append(inputParts, commandSuggestPopup)
+	}
+	if search := m.RenderInputSearch(); search != "" {
+		inputParts = append(inputParts, search)
+	}
+	if search := m.RenderChatSearch(); search != "" {
+		inputParts = append(inputParts, search)
+	}
+	if themes := m.RenderThemeSelector(); themes != "" {
+		inputParts = append(inputParts, themes)
+	}
+	if queue := m.RenderQueue(); queue != "" {
+		inputParts = append(inputParts, queue)
+	}
 	inputParts = append(inputParts, inputBox)
 	inputSection = lipgloss.JoinVertical(lipgloss.Left, inputParts...)
 
 	chatContent := lipgloss.JoinVertical(lipgloss.Center,
 		styles.TitleStyle.Render("ARCANE AI"),
-		"",
+		m.RenderTabBar(),
 		m.Viewport.View(),
 		"",
 		inputSection,
@@ -482,6 +590,51 @@ func (m *Model) View() string {
 			))
 	}
 
+	if m.CopySelectorOpen {
+		modal := m.RenderCopySelector()
+		modal = styles.ModalStyle.Width(ModalWidth).Render(modal)
+
+		return lipgloss.NewStyle().
+			Background(lipgloss.Color("rgba(0,0,0,0.7)")).
+			Render(lipgloss.Place(
+				m.WindowWidth,
+				m.WindowHeight,
+				lipgloss.Center,
+				lipgloss.Center,
+				modal,
+			))
+	}
+
+	if m.PaletteOpen {
+		modal := m.RenderPalette()
+		modal = styles.ModalStyle.Width(ModalWidth).Render(modal)
+
+		return lipgloss.NewStyle().
+			Background(lipgloss.Color("rgba(0,0,0,0.7)")).
+			Render(lipgloss.Place(
+				m.WindowWidth,
+				m.WindowHeight,
+				lipgloss.Center,
+				lipgloss.Center,
+				modal,
+			))
+	}
+
+	if m.TemplateSelectorOpen {
+		modal := m.RenderTemplateSelector()
+		modal = styles.ModalStyle.Width(ModalWidth).Render(modal)
+
+		return lipgloss.NewStyle().
+			Background(lipgloss.Color("rgba(0,0,0,0.7)")).
+			Render(lipgloss.Place(
+				m.WindowWidth,
+				m.WindowHeight,
+				lipgloss.Center,
+				lipgloss.Center,
+				modal,
+			))
+	}
+
 	if m.ModelSelectorOpen {
 		modal := m.RenderModelSelector()
 		modal = styles.ModalStyle.Width(ModalWidth).Render(modal)
@@ -497,6 +650,21 @@ func (m *Model) View() string {
 			))
 	}
 
+	if m.PersonaSelectorOpen {
+		modal := m.RenderPersonaSelector()
+		modal = styles.ModalStyle.Width(ModalWidth).Render(modal)
+
+		return lipgloss.NewStyle().
+			Background(lipgloss.Color("rgba(0,0,0,0.7)")).
+			Render(lipgloss.Place(
+				m.WindowWidth,
+				m.WindowHeight,
+				lipgloss.Center,
+				lipgloss.Center,
+				modal,
+			))
+	}
+
 	if m.ShortcutsOpen {
 		modal := m.RenderShortcutsModal()
 		modal = styles.ModalStyle.Width(ModalWidth).Render(modal)
@@ -1,13 +1,33 @@
 package main
 
 import (
+	"arcane/internal/cli"
 	"arcane/internal/ui"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 )
 
 func main() {
-	p := ui.NewProgram()
+	if handled, err := cli.Run(os.Args[1:], os.Stdout, os.Stderr); handled {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts, err := parseStartOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	p, err := ui.NewProgram(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	finalModel, err := p.Run()
 	if err != nil {
 		fmt.Printf("Error: %v", err)
@@ -19,3 +39,28 @@ func main() {
 		}
 	}
 }
+
+func parseStartOptions(args []string) (ui.StartOptions, error) {
+	var opts ui.StartOptions
+	fs := flag.NewFlagSet("arcane", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Int64Var(&opts.ResumeChatID, "resume", 0, "open the chat with this id")
+	fs.BoolVar(&opts.Continue, "continue", false, "open the most recent chat")
+	fs.BoolVar(&opts.ContinueHere, "here", false, "with --continue, only chats started in the current directory")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unknown command %q (see arcane help)", fs.Arg(0))
+	}
+	if opts.ResumeChatID < 0 {
+		return opts, fmt.Errorf("invalid chat id %d", opts.ResumeChatID)
+	}
+	if opts.ResumeChatID != 0 && opts.Continue {
+		return opts, fmt.Errorf("--resume and --continue cannot be combined")
+	}
+	if opts.ContinueHere && !opts.Continue {
+		return opts, fmt.Errorf("--here only applies to --continue")
+	}
+	return opts, nil
+}